@@ -0,0 +1,3 @@
+module github.com/TheNoeTrevino/no-go.nvim/cmd
+
+go 1.25.0
@@ -0,0 +1,53 @@
+// Package golden compares the output of the tools' tests with the golden files
+// under their testdata directory. go test -update rewrites the files instead.
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// Check compares got with the golden file at path, or writes it with -update.
+func Check(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (go test -update writes it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+// Cases returns the directories under testdata/src, one test case each.
+func Cases(t *testing.T) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join("testdata", "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			cases = append(cases, entry.Name())
+		}
+	}
+	return cases
+}
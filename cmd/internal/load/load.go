@@ -0,0 +1,266 @@
+// Package load parses and type checks Go packages with the standard library only.
+//
+// Imports are read from the export data `go list -export` builds, and from the
+// standard library's when go list fails (outside of a module). Type checking is
+// best effort: imports that can't be found (a module that isn't downloaded, a
+// build that doesn't compile) leave holes in the type information instead of
+// failing the load, so callers must expect missing types.
+package load
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is a type checked package, one per directory and package clause
+// (an external foo_test package is its own Package).
+type Package struct {
+	Dir   string
+	Name  string
+	Files []*ast.File
+	Types *types.Package
+	Info  *types.Info
+	// Errors are the type errors found while checking, usually unresolved imports
+	Errors []error
+}
+
+// chainImporter returns the package of the first importer that finds it.
+type chainImporter []types.Importer
+
+func (c chainImporter) Import(path string) (*types.Package, error) {
+	var err error
+	for _, imp := range c {
+		var pkg *types.Package
+		if pkg, err = imp.Import(path); err == nil {
+			return pkg, nil
+		}
+	}
+	return nil, err
+}
+
+// exportData maps import paths to the export data files go list builds for the
+// packages in dirs and their dependencies. It is empty when go list fails.
+func exportData(dirs []string) map[string]string {
+	exports := map[string]string{}
+	if len(dirs) == 0 {
+		return exports
+	}
+
+	args := append([]string{"list", "-e", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}"}, dirs...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dirs[0]
+	out, _ := cmd.Output()
+
+	for _, line := range strings.Split(string(out), "\n") {
+		path, file, ok := strings.Cut(line, "\t")
+		if ok && file != "" {
+			exports[path] = file
+		}
+	}
+	return exports
+}
+
+// Dirs expands patterns into the directories holding Go files. A pattern is a
+// directory, or a directory followed by /... for it and everything below it.
+// vendor, testdata, and directories starting with . or _ are skipped, like the go tool does.
+func Dirs(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] && hasGoFiles(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, pattern := range patterns {
+		root, recursive := strings.CutSuffix(pattern, "...")
+		root = filepath.Clean(strings.TrimSuffix(root, "/"))
+		if root == "" {
+			root = "."
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+
+		if !recursive {
+			add(abs)
+			continue
+		}
+
+		err = filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			name := d.Name()
+			if path != abs && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			add(path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func hasGoFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// Packages parses and type checks the packages of the directories matched by
+// patterns. Files that fail to parse are skipped, _test.go files are only loaded
+// with tests.
+func Packages(fset *token.FileSet, patterns []string, tests bool) ([]*Package, error) {
+	dirs, err := Dirs(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := exportData(dirs)
+	imp := chainImporter{
+		importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+			file, ok := exports[path]
+			if !ok {
+				return nil, fmt.Errorf("no export data for %s", path)
+			}
+			return os.Open(file)
+		}),
+		importer.Default(),
+	}
+
+	var packages []*Package
+	for _, dir := range dirs {
+		files, err := parseDir(fset, dir, tests)
+		if err != nil {
+			return nil, err
+		}
+
+		// group by package clause, foo and foo_test are checked apart
+		byName := map[string][]*ast.File{}
+		var names []string
+		for _, file := range files {
+			name := file.Name.Name
+			if _, ok := byName[name]; !ok {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], file)
+		}
+
+		for _, name := range names {
+			packages = append(packages, check(fset, imp, dir, name, byName[name]))
+		}
+	}
+	return packages, nil
+}
+
+func parseDir(fset *token.FileSet, dir string, tests bool) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if !tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func check(fset *token.FileSet, imp types.Importer, dir, name string, files []*ast.File) *Package {
+	pkg := &Package{
+		Dir:   dir,
+		Name:  name,
+		Files: files,
+		Info: &types.Info{
+			Types:      map[ast.Expr]types.TypeAndValue{},
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+		},
+	}
+
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			pkg.Errors = append(pkg.Errors, err)
+		},
+	}
+	// the error is the first of pkg.Errors, checking goes on after it
+	pkg.Types, _ = conf.Check(name, fset, files, pkg.Info)
+	return pkg
+}
+
+// Matches reports whether name matches one of the patterns, a pattern ending
+// in * matches by prefix.
+func Matches(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Relative returns file relative to dir when it is below it, and file unchanged
+// otherwise.
+func Relative(dir, file string) string {
+	if rel, err := filepath.Rel(dir, file); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return file
+}
+
+// List splits a comma separated flag value, dropping empty items.
+func List(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
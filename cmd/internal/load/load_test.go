@@ -0,0 +1,137 @@
+package load
+
+import (
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirs(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"testdata/tree/a", []string{"a"}},
+		// testdata, vendor, _ and . directories are skipped, and so are those without Go files
+		{"testdata/tree/a/...", []string{"a", "a/b"}},
+		{"testdata/tree/...", []string{"a", "a/b"}},
+		{"testdata/tree/a/docs", nil},
+	}
+
+	root, err := filepath.Abs("testdata/tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			dirs, err := Dirs([]string{tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, dir := range dirs {
+				rel, err := filepath.Rel(root, dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dirs(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackages(t *testing.T) {
+	tests := []struct {
+		tests bool
+		want  []string
+	}{
+		{false, []string{"a"}},
+		// the external test package is checked apart
+		{true, []string{"a", "a_test"}},
+	}
+
+	for _, tt := range tests {
+		fset := token.NewFileSet()
+		packages, err := Packages(fset, []string{"testdata/tree/a"}, tt.tests)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, pkg := range packages {
+			names = append(names, pkg.Name)
+			if len(pkg.Errors) > 0 {
+				t.Errorf("package %s: unexpected errors %v", pkg.Name, pkg.Errors)
+			}
+			if pkg.Types == nil {
+				t.Errorf("package %s is not type checked", pkg.Name)
+			}
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("Packages(tests=%v) = %q, want %q", tt.tests, names, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     bool
+	}{
+		{"ShouldBindJSON", []string{"ShouldBind*"}, true},
+		{"c.ShouldBindJSON", []string{"ShouldBind*"}, false},
+		{"Decode", []string{"ShouldBind*", "Decode"}, true},
+		{"DecodeAll", []string{"Decode"}, false},
+		{"anything", []string{"*"}, true},
+		{"anything", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.name, tt.patterns); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.name, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestRelative(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"/src/a.go", "a.go"},
+		{"/src/pkg/a.go", "pkg/a.go"},
+		// a name starting with two dots is still below the directory
+		{"/src/..foo/a.go", "..foo/a.go"},
+		{"/other/a.go", "/other/a.go"},
+		{"/src", "."},
+	}
+
+	for _, tt := range tests {
+		file, want := filepath.FromSlash(tt.file), filepath.FromSlash(tt.want)
+		if got := Relative(filepath.FromSlash("/src"), file); got != want {
+			t.Errorf("Relative(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{" a, b ,,c ", []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		if got := List(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package a
+
+import "strings"
+
+// Upper is used by the external test package.
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
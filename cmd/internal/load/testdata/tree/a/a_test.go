@@ -0,0 +1,8 @@
+package a_test
+
+import "fmt"
+
+func Example() {
+	fmt.Println("a")
+	// Output: a
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// Finding is an error string that breaks a convention.
+type Finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Rule is a convention, as listed in the SARIF output.
+type Rule struct {
+	ID          string
+	Description string
+}
+
+// Rules are the conventions checked, in the order they are checked.
+var Rules = []Rule{
+	{"capitalized", "Error strings start with a lowercase letter, unless they start with an acronym or identifier."},
+	{"punctuation", "Error strings don't end with punctuation or a newline."},
+	{"failed-to", "Error strings don't start with \"failed to\", the error already says something failed."},
+	{"error-prefix", "Error strings don't start with \"error:\", the caller knows it has an error."},
+	{"duplicated-context", "A wrapped error doesn't repeat the context the wrapping message adds."},
+}
+
+// errorString is an errors.New or fmt.Errorf call with a literal message.
+type errorString struct {
+	call    *ast.CallExpr
+	lit     *ast.BasicLit
+	message string
+	// wrapped is the operand of the first %w, nil without one
+	wrapped ast.Expr
+}
+
+type checker struct {
+	fset     *token.FileSet
+	pkg      *load.Package
+	findings []Finding
+	// contexts are the first segments of the messages a function of the
+	// package returns, as its callers see them
+	contexts map[types.Object][]string
+}
+
+// Check reports the error strings of a package that break a convention.
+func Check(fset *token.FileSet, pkg *load.Package) []Finding {
+	c := &checker{fset: fset, pkg: pkg, contexts: map[types.Object][]string{}}
+
+	for _, file := range pkg.Files {
+		c.collectContexts(file)
+	}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Body != nil {
+					c.declaration(decl)
+				}
+			case *ast.GenDecl:
+				// package level sentinels, var ErrX = errors.New("...")
+				c.declaration(decl)
+			}
+		}
+	}
+
+	sort.Slice(c.findings, func(i, j int) bool {
+		a, b := c.findings[i], c.findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return c.findings
+}
+
+// asErrorString returns the error string built by a call, or nil if the call
+// is not errors.New or fmt.Errorf with a literal message.
+func (c *checker) asErrorString(expr ast.Expr) *errorString {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return nil
+	}
+
+	name := c.callee(call)
+	if name != "errors.New" && name != "fmt.Errorf" {
+		return nil
+	}
+
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil
+	}
+	message, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil
+	}
+
+	s := &errorString{call: call, lit: lit, message: message}
+	if name == "fmt.Errorf" {
+		if i := wrapIndex(message); i >= 0 && i+1 < len(call.Args) {
+			s.wrapped = call.Args[i+1]
+		}
+	}
+	return s
+}
+
+// callee returns the qualified name of the called function (fmt.Errorf), or the
+// call as written when its package can't be resolved.
+func (c *checker) callee(call *ast.CallExpr) string {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if fn, ok := c.pkg.Info.Uses[sel.Sel].(*types.Func); ok {
+		return fn.FullName()
+	}
+	return types.ExprString(sel)
+}
+
+// object returns the function or variable an expression refers to.
+func (c *checker) object(expr ast.Expr) types.Object {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if obj := c.pkg.Info.Uses[expr]; obj != nil {
+			return obj
+		}
+		return c.pkg.Info.Defs[expr]
+	case *ast.SelectorExpr:
+		return c.pkg.Info.Uses[expr.Sel]
+	}
+	return nil
+}
+
+// collectContexts records what the functions of a file return: the first
+// segment of each error string they return directly.
+func (c *checker) collectContexts(file *ast.File) {
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		obj := c.pkg.Info.Defs[fn.Name]
+		if obj == nil {
+			continue
+		}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				for _, result := range n.Results {
+					if s := c.asErrorString(result); s != nil {
+						if first := segments(s.message); len(first) > 0 {
+							c.contexts[obj] = append(c.contexts[obj], first[0])
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// declaration checks the error strings of a declaration, following in source
+// order what each variable was last assigned to find the context of a %w.
+func (c *checker) declaration(decl ast.Decl) {
+	// assigned maps a variable to the first segments of the messages it holds
+	assigned := map[types.Object][]string{}
+
+	// assignments take effect once their right side was checked, err = fmt.Errorf("x: %w", err)
+	// wraps what err held before
+	var stack []ast.Node
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == nil {
+			if assign, ok := stack[len(stack)-1].(*ast.AssignStmt); ok {
+				c.assign(assign, assigned)
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+
+		if call, ok := n.(*ast.CallExpr); ok {
+			if s := c.asErrorString(call); s != nil {
+				c.style(s)
+				if s.wrapped != nil {
+					c.duplicated(s, c.contextsOf(s.wrapped, assigned))
+				}
+			}
+		}
+		return true
+	})
+}
+
+// assign records the messages an assignment puts in its variables.
+func (c *checker) assign(n *ast.AssignStmt, assigned map[types.Object][]string) {
+	if len(n.Lhs) == len(n.Rhs) {
+		for i, lhs := range n.Lhs {
+			if obj := c.object(lhs); obj != nil {
+				assigned[obj] = c.contextsOf(n.Rhs[i], assigned)
+			}
+		}
+	} else if len(n.Rhs) == 1 {
+		// v, err := f(), the error comes last
+		if obj := c.object(n.Lhs[len(n.Lhs)-1]); obj != nil {
+			assigned[obj] = c.contextsOf(n.Rhs[0], assigned)
+		}
+	}
+}
+
+// contextsOf returns the first segments of the messages an expression may hold.
+func (c *checker) contextsOf(expr ast.Expr, assigned map[types.Object][]string) []string {
+	if s := c.asErrorString(expr); s != nil {
+		parts := segments(s.message)
+		return parts[:min(1, len(parts))]
+	}
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+		return c.contexts[c.object(call.Fun)]
+	}
+	return assigned[c.object(expr)]
+}
+
+// style checks the message of an error string on its own.
+func (c *checker) style(s *errorString) {
+	message := s.message
+	if message == "" {
+		return
+	}
+
+	first, _ := utf8.DecodeRuneInString(message)
+	if unicode.IsUpper(first) && !isIdentifierLike(firstWord(message)) {
+		c.report(s.lit, "capitalized", "error string should not be capitalized")
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(message)
+	if strings.ContainsRune(".!?:;\n", last) {
+		c.report(s.lit, "punctuation", "error string should not end with punctuation or a newline")
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case strings.HasPrefix(lower, "failed to ") || strings.HasPrefix(lower, "unable to "):
+		c.report(s.lit, "failed-to", fmt.Sprintf("error string should not start with %q", firstWords(message, 2)))
+	case strings.HasPrefix(lower, "error:") || strings.HasPrefix(lower, "err:") || strings.HasPrefix(lower, "error "):
+		c.report(s.lit, "error-prefix", fmt.Sprintf("error string should not start with %q", firstWord(message)))
+	}
+
+	// the same context twice in one message: "decode: decode: %w"
+	parts := segments(message)
+	for i := 1; i < len(parts); i++ {
+		if strings.EqualFold(parts[i-1], parts[i]) {
+			c.report(s.lit, "duplicated-context", fmt.Sprintf("context %q is repeated", parts[i]))
+			return
+		}
+	}
+}
+
+// duplicated reports a %w whose wrapped error already starts with the context
+// the message adds.
+func (c *checker) duplicated(s *errorString, wrapped []string) {
+	parts := segments(s.message)
+	if len(parts) == 0 {
+		return
+	}
+	context := parts[len(parts)-1]
+
+	for _, first := range wrapped {
+		if sameContext(context, first) {
+			c.report(s.lit, "duplicated-context", fmt.Sprintf("context %q is already in the wrapped error", context))
+			return
+		}
+	}
+}
+
+func (c *checker) report(node ast.Node, rule, message string) {
+	pos := c.fset.Position(node.Pos())
+	c.findings = append(c.findings, Finding{
+		File:    pos.Filename,
+		Line:    pos.Line,
+		Col:     pos.Column,
+		Rule:    rule,
+		Message: message,
+	})
+}
+
+// segments splits a message on ": " into its contexts, leaving out the
+// segments that are only a verb ("%w").
+func segments(message string) []string {
+	var parts []string
+	for _, part := range strings.Split(message, ": ") {
+		part = strings.TrimSpace(part)
+		if part == "" || (strings.HasPrefix(part, "%") && len(part) == 2) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// sameContext reports whether two contexts say the same thing, one being the
+// other or starting with it ("read config" and "read config file").
+func sameContext(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a != "" && (a == b || strings.HasPrefix(b, a+" "))
+}
+
+// wrapIndex returns the index among the arguments of a format of the operand
+// of its first %w, or -1. It reads the verbs like fmt does: %% takes no
+// argument, a * width or precision takes one, and [n] picks the argument of
+// the width, precision or verb after it.
+func wrapIndex(format string) int {
+	arg := 0
+	// explicit reads an explicit argument index at format[i:], and returns
+	// where what follows it starts
+	explicit := func(i int) int {
+		if i >= len(format) || format[i] != '[' {
+			return i
+		}
+		end := strings.IndexByte(format[i:], ']')
+		if end < 0 {
+			return i
+		}
+		if n, err := strconv.Atoi(format[i+1 : i+end]); err == nil && n > 0 {
+			arg = n - 1
+		}
+		return i + end + 1
+	}
+	// number skips a width or precision, a * takes an argument
+	number := func(i int) int {
+		i = explicit(i)
+		if i < len(format) && format[i] == '*' {
+			arg++
+			return i + 1
+		}
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		return i
+	}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		i = number(i)
+		if i < len(format) && format[i] == '.' {
+			i = number(i + 1)
+		}
+		i = explicit(i)
+		if i >= len(format) {
+			break
+		}
+
+		switch format[i] {
+		case '%':
+			continue
+		case 'w':
+			return arg
+		}
+		arg++
+	}
+	return -1
+}
+
+func firstWord(message string) string {
+	return firstWords(message, 1)
+}
+
+func firstWords(message string, n int) string {
+	words := strings.Fields(message)
+	return strings.Join(words[:min(n, len(words))], " ")
+}
+
+// isIdentifierLike reports whether a word is an acronym or identifier that
+// keeps its case: HTTP, JSON, ShouldBindJSON, IDs.
+func isIdentifierLike(word string) bool {
+	word = strings.TrimRight(word, ":,.")
+	for i, r := range word {
+		if i > 0 && (unicode.IsUpper(r) || unicode.IsDigit(r) || r == '_' || r == '.') {
+			return true
+		}
+	}
+	return false
+}
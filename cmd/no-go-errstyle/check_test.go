@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/golden"
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// TestCheck runs the checker on each package of testdata/src, and compares the
+// JSON and SARIF outputs with testdata/<package>.json and testdata/<package>.sarif.
+func TestCheck(t *testing.T) {
+	for _, name := range golden.Cases(t) {
+		t.Run(name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			dir, err := filepath.Abs(filepath.Join("testdata", "src", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			packages, err := load.Packages(fset, []string{dir}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings := []Finding{}
+			for _, pkg := range packages {
+				findings = append(findings, Check(fset, pkg)...)
+			}
+
+			// the SARIF paths are relative to the package, the JSON ones are made so,
+			// the golden files don't depend on the checkout
+			log := sarif(findings, dir)
+			log.Runs[0].OriginalURIBaseIDs["SRCROOT"] = sarifArtifactURI{URI: "file:///src/"}
+			var out bytes.Buffer
+			if err := encode(&out, log); err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, filepath.Join("testdata", name+".sarif"), out.Bytes())
+
+			for i := range findings {
+				findings[i].File = filepath.ToSlash(load.Relative(dir, findings[i].File))
+			}
+			out.Reset()
+			if err := encode(&out, findings); err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, filepath.Join("testdata", name+".json"), out.Bytes())
+		})
+	}
+}
+
+func TestWrapIndex(t *testing.T) {
+	tests := []struct {
+		format string
+		want   int
+	}{
+		{"no verbs", -1},
+		{"decode: %w", 0},
+		{"%s: %w", 1},
+		{"%v", -1},
+		// %% takes no argument
+		{"100%% sure: %w", 0},
+		{"%d%%: %w", 1},
+		// * widths and precisions take one
+		{"%*d: %w", 2},
+		{"%.*f: %w", 2},
+		{"%*.*f: %w", 3},
+		{"%-8s|%08.3f: %w", 2},
+		// explicit indexes pick the argument
+		{"%[2]w: %[1]s", 1},
+		{"%[1]s %[1]q: %w", 1},
+		{"%[3]*d: %w", 4},
+		{"%[2]*[1]d: %w", 1},
+		{"%s: %w: %w", 1},
+		{"trailing %", -1},
+	}
+
+	for _, tt := range tests {
+		if got := wrapIndex(tt.format); got != tt.want {
+			t.Errorf("wrapIndex(%q) = %d, want %d", tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Command no-go-errstyle checks the error strings of errors.New and fmt.Errorf
+// calls against the Go conventions: they start lowercase, don't end with
+// punctuation, don't start with "failed to" or "error:", and a %w doesn't
+// repeat the context the wrapped error already has, so a chain reads like
+// "bind request: decode: invalid character".
+//
+// It only needs the standard library.
+//
+// Usage:
+//
+//	no-go-errstyle [-format text|json|sarif] [packages]
+//
+// Packages are directories, dir/... includes everything below dir. The default
+// is the current directory. The exit status is 1 when something is reported.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+var (
+	formatFlag = flag.String("format", "text", "output format: text, json or sarif")
+	testsFlag  = flag.Bool("tests", true, "check _test.go files too")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: no-go-errstyle [flags] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *formatFlag != "text" && *formatFlag != "json" && *formatFlag != "sarif" {
+		fmt.Fprintf(os.Stderr, "no-go-errstyle: unknown format %q\n", *formatFlag)
+		os.Exit(2)
+	}
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	fset := token.NewFileSet()
+	packages, err := load.Packages(fset, patterns, *testsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no-go-errstyle: %v\n", err)
+		os.Exit(2)
+	}
+
+	findings := []Finding{}
+	for _, pkg := range packages {
+		findings = append(findings, Check(fset, pkg)...)
+	}
+
+	cwd, _ := os.Getwd()
+	switch *formatFlag {
+	case "json":
+		err = encode(os.Stdout, findings)
+	case "sarif":
+		err = encode(os.Stdout, sarif(findings, cwd))
+	default:
+		for _, finding := range findings {
+			fmt.Printf("%s:%d:%d: %s (%s)\n", load.Relative(cwd, finding.File), finding.Line, finding.Col, finding.Message, finding.Rule)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no-go-errstyle: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// encode writes a value as indented JSON.
+func encode(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// The subset of SARIF 2.1.0 code scanning tools read.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// sarif converts the findings to a SARIF log, with paths relative to root
+// (the SRCROOT base) when they are below it.
+func sarif(findings []Finding, root string) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{Name: "no-go-errstyle"}},
+		OriginalURIBaseIDs: map[string]sarifArtifactURI{
+			"SRCROOT": {URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(root) + "/"}).String()},
+		},
+		Results: []sarifResult{},
+	}
+	for _, rule := range Rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               rule.ID,
+			ShortDescription: sarifMessage{Text: rule.Description},
+		})
+	}
+
+	for _, finding := range findings {
+		artifact := sarifArtifactURI{URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(finding.File)}).String()}
+		if rel := load.Relative(root, finding.File); rel != finding.File {
+			artifact = sarifArtifactURI{URI: filepath.ToSlash(rel), URIBaseID: "SRCROOT"}
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  finding.Rule,
+			Level:   "warning",
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: artifact,
+					Region:           sarifRegion{StartLine: finding.Line, StartColumn: finding.Col},
+				},
+			}},
+		})
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
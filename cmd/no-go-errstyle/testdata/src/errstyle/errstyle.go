@@ -0,0 +1,44 @@
+package errstyle
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCapitalized = errors.New("Something broke")
+	ErrAcronym     = errors.New("HTTP request broke")
+	ErrPunctuation = errors.New("something broke.")
+	ErrFine        = errors.New("something broke")
+)
+
+func failedTo() error {
+	return errors.New("failed to open the file")
+}
+
+func errorPrefix() error {
+	return errors.New("error: open the file")
+}
+
+func repeated(err error) error {
+	return fmt.Errorf("decode: decode: %w", err)
+}
+
+func readConfig() error {
+	return errors.New("read config: no such file")
+}
+
+// the wrapped error already says "read config"
+func load() error {
+	if err := readConfig(); err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	return nil
+}
+
+// err holds what it was last assigned
+func wrapTwice() error {
+	err := errors.New("bind request: invalid body")
+	err = fmt.Errorf("bind request: %w", err)
+	return fmt.Errorf("handle: %w", err)
+}
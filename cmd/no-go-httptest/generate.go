@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TestFile returns the name of the test file generated for a source file:
+// handlers.go gets handlers_errpaths_test.go.
+func TestFile(source string) string {
+	return strings.TrimSuffix(source, ".go") + "_errpaths_test.go"
+}
+
+// Generate writes the test skeleton for the handlers of one source file: a
+// table-driven test per handler, one case per error path, with the expected
+// status and body message filled in and the inputs left to write.
+func Generate(pkgName, source string, handlers []Handler) ([]byte, error) {
+	var b bytes.Buffer
+	gin := false
+	for _, h := range handlers {
+		gin = gin || h.Kind == "gin"
+	}
+
+	fmt.Fprintf(&b, "// Error paths of the handlers in %s, generated by no-go-httptest.\n", filepath.Base(source))
+	fmt.Fprintf(&b, "// Each case expects the status and message of one error block, fill in the input\n")
+	fmt.Fprintf(&b, "// that makes it fail.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "import (\n\t\"net/http\"\n\t\"net/http/httptest\"\n\t\"strings\"\n\t\"testing\"\n")
+	if gin {
+		fmt.Fprintf(&b, "\n\t%q\n", ginPath)
+	}
+	fmt.Fprintf(&b, ")\n")
+
+	// test names taken, two routes can make the same name
+	names := map[string]bool{}
+	for _, h := range handlers {
+		b.WriteString("\n")
+		writeTest(&b, h, names)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format the test of %s: %w", source, err)
+	}
+	return src, nil
+}
+
+func writeTest(b *bytes.Buffer, h Handler, names map[string]bool) {
+	name := h.Name
+	call := h.Name
+	if h.Recv != "" {
+		name = h.Recv + exported(h.Name)
+		call = "h." + h.Name
+	}
+	test := "Test" + exported(name) + "ErrorPaths"
+	if names[test] {
+		test = fmt.Sprintf("Test%sLine%dErrorPaths", exported(name), h.Line)
+	}
+	names[test] = true
+
+	fmt.Fprintf(b, "func %s(t *testing.T) {\n", test)
+	if h.Kind == "gin" {
+		fmt.Fprintf(b, "gin.SetMode(gin.TestMode)\n")
+	}
+	if h.Registrar != "" {
+		// a func literal has no name to call it by
+		typ := "http.HandlerFunc"
+		if h.Kind == "gin" {
+			typ = "gin.HandlerFunc"
+		}
+		where := fmt.Sprintf("%s:%d", filepath.Base(h.File), h.Line)
+		if h.Route != "" {
+			where = fmt.Sprintf("%q (%s)", h.Route, where)
+		}
+		fmt.Fprintf(b, "var handler %s // TODO: the handler %s registers for %s\n", typ, h.Registrar, where)
+		call = "handler"
+	}
+	if h.Recv != "" {
+		amp := ""
+		if h.Pointer {
+			amp = "&"
+		}
+		fmt.Fprintf(b, "h := %s%s{} // TODO: set up the receiver\n", amp, h.Recv)
+	}
+	fmt.Fprintf(b, "\ntests := []struct {\nname string\nbody string\nwantStatus int\nwantBody string\n}{\n")
+
+	used := map[string]int{}
+	for _, p := range h.Paths {
+		caseName := "line " + strconv.Itoa(p.Line)
+		if p.Call != "" {
+			caseName = p.Call + " fails"
+		}
+		if used[caseName]++; used[caseName] > 1 {
+			caseName = fmt.Sprintf("%s (%d)", caseName, used[caseName])
+		}
+
+		fmt.Fprintf(b, "{\n// %s:%d\n", filepath.Base(h.File), p.Line)
+		fmt.Fprintf(b, "name: %q,\n", caseName)
+		if p.Call != "" {
+			fmt.Fprintf(b, "body: \"\", // TODO: a request that makes %s fail\n", p.Call)
+		} else {
+			fmt.Fprintf(b, "body: \"\", // TODO: a request that takes this path\n")
+		}
+		switch {
+		case !p.Writes:
+			fmt.Fprintf(b, "wantStatus: http.StatusOK, // the block writes nothing, the default status\n")
+		case p.Status == "" && p.StatusExpr != "":
+			fmt.Fprintf(b, "wantStatus: 0, // TODO: the handler writes %s\n", p.StatusExpr)
+		case p.Status == "":
+			fmt.Fprintf(b, "wantStatus: 0, // TODO: the status the block writes\n")
+		default:
+			fmt.Fprintf(b, "wantStatus: %s,\n", p.Status)
+		}
+		if p.Writes && p.Message == "" {
+			fmt.Fprintf(b, "wantBody: \"\", // TODO: the body is not a literal\n")
+		} else {
+			fmt.Fprintf(b, "wantBody: %q,\n", p.Message)
+		}
+		fmt.Fprintf(b, "},\n")
+	}
+	fmt.Fprintf(b, "}\n\n")
+
+	fmt.Fprintf(b, "for _, tt := range tests {\nt.Run(tt.name, func(t *testing.T) {\n")
+	fmt.Fprintf(b, "// TODO: the method and route of the handler\n")
+	fmt.Fprintf(b, "req := httptest.NewRequest(http.MethodPost, \"/\", strings.NewReader(tt.body))\n")
+	fmt.Fprintf(b, "req.Header.Set(\"Content-Type\", \"application/json\")\n")
+	fmt.Fprintf(b, "w := httptest.NewRecorder()\n\n")
+	if h.Kind == "gin" {
+		fmt.Fprintf(b, "c, _ := gin.CreateTestContext(w)\nc.Request = req\n%s(c)\n\n", call)
+	} else {
+		fmt.Fprintf(b, "%s(w, req)\n\n", call)
+	}
+	fmt.Fprintf(b, "if w.Code != tt.wantStatus {\n")
+	fmt.Fprintf(b, "t.Errorf(\"status = %%d, want %%d\", w.Code, tt.wantStatus)\n}\n")
+	fmt.Fprintf(b, "if !strings.Contains(w.Body.String(), tt.wantBody) {\n")
+	fmt.Fprintf(b, "t.Errorf(\"body = %%q, want it to contain %%q\", w.Body.String(), tt.wantBody)\n}\n")
+	fmt.Fprintf(b, "})\n}\n}\n")
+}
+
+// exported upper-cases the first letter of a name.
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+const ginPath = "github.com/gin-gonic/gin"
+
+// Handler is a gin or net/http handler and the error paths found in it.
+type Handler struct {
+	File string
+	Line int
+	// Kind is gin (func(c *gin.Context)) or http (func(w http.ResponseWriter, r *http.Request))
+	Kind string
+	Name string
+	// Recv is the receiver type of a method, "" for a function
+	Recv    string
+	Pointer bool
+	// Registrar is the function registering a func literal handler,
+	// mux.HandleFunc("/x", func(...) {...}), and Route the route it is
+	// registered for. A test can't call those by name.
+	Registrar string
+	Route     string
+	Paths     []Path
+}
+
+// Path is an error-handling block of a handler and the response it writes.
+type Path struct {
+	Line int
+	// Call is the call whose error the block handles, as written, "" when unknown
+	Call string
+	// Writes is false when the block returns without writing a response
+	Writes bool
+	// Status is the status code as a test can write it, "" when it can't be
+	// copied (StatusExpr holds what the handler wrote)
+	Status     string
+	StatusExpr string
+	// Message is the literal message of the body, "" when it isn't a literal
+	Message string
+}
+
+var errorInterface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+type finder struct {
+	fset *token.FileSet
+	pkg  *load.Package
+	// imports maps an import path to the name the file uses for it
+	imports map[string]string
+}
+
+// Handlers returns the handlers of a package whose name matches funcs (all of
+// them when funcs is empty), in source order. Handlers are functions, methods,
+// package variables holding a func literal, and func literals registered with
+// HandleFunc, Handle or a gin route method, which match funcs by their name or
+// their registrar's.
+func Handlers(fset *token.FileSet, pkg *load.Package, funcs []string) []Handler {
+	matches := func(names ...string) bool {
+		for _, name := range names {
+			if len(funcs) == 0 || load.Matches(name, funcs) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var handlers []Handler
+	for _, file := range pkg.Files {
+		f := &finder{fset: fset, pkg: pkg, imports: importNames(file)}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Body == nil {
+					continue
+				}
+				if h, ok := f.handler(decl); ok && matches(h.Name) {
+					handlers = append(handlers, h)
+				}
+				for _, h := range f.registered(decl.Body, decl.Name.Name) {
+					if matches(h.Name, h.Registrar) {
+						handlers = append(handlers, h)
+					}
+				}
+			case *ast.GenDecl:
+				for _, h := range f.variables(decl) {
+					if matches(h.Name) {
+						handlers = append(handlers, h)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(handlers, func(i, j int) bool {
+		if handlers[i].File != handlers[j].File {
+			return handlers[i].File < handlers[j].File
+		}
+		return handlers[i].Line < handlers[j].Line
+	})
+	return handlers
+}
+
+// importNames maps the import paths of a file to the names it refers to them by.
+func importNames(file *ast.File) map[string]string {
+	names := map[string]string{}
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[p] = name
+	}
+	return names
+}
+
+// isNamed reports whether expr is the type pkg.name (or *pkg.name with pointer) as
+// written in the file.
+func (f *finder) isNamed(expr ast.Expr, pkgPath, name string, pointer bool) bool {
+	if pointer {
+		star, ok := expr.(*ast.StarExpr)
+		if !ok {
+			return false
+		}
+		expr = star.X
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == f.imports[pkgPath] && sel.Sel.Name == name
+}
+
+// handler recognizes a handler by its parameters, and collects its error paths.
+func (f *finder) handler(fn *ast.FuncDecl) (Handler, bool) {
+	h := Handler{
+		File: f.fset.Position(fn.Pos()).Filename,
+		Line: f.fset.Position(fn.Pos()).Line,
+		Name: fn.Name.Name,
+	}
+	if fn.Recv != nil && len(fn.Recv.List) == 1 {
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			h.Pointer = true
+			recv = star.X
+		}
+		if index, ok := recv.(*ast.IndexExpr); ok {
+			recv = index.X
+		}
+		ident, ok := recv.(*ast.Ident)
+		if !ok {
+			return h, false
+		}
+		h.Recv = ident.Name
+	}
+
+	return h, f.function(&h, fn.Type, fn.Body)
+}
+
+// function recognizes a handler by the parameters of its function type, and
+// fills in its kind and error paths.
+func (f *finder) function(h *Handler, typ *ast.FuncType, body *ast.BlockStmt) bool {
+	var params []*ast.Ident
+	var paramTypes []ast.Expr
+	for _, field := range typ.Params.List {
+		if len(field.Names) == 0 {
+			params = append(params, nil)
+			paramTypes = append(paramTypes, field.Type)
+		}
+		for _, name := range field.Names {
+			params = append(params, name)
+			paramTypes = append(paramTypes, field.Type)
+		}
+	}
+
+	var writer *ast.Ident
+	switch {
+	case len(params) == 1 && f.isNamed(paramTypes[0], ginPath, "Context", true):
+		h.Kind = "gin"
+		writer = params[0]
+	case len(params) == 2 && f.isNamed(paramTypes[0], "net/http", "ResponseWriter", false) &&
+		f.isNamed(paramTypes[1], "net/http", "Request", true):
+		h.Kind = "http"
+		writer = params[0]
+	default:
+		return false
+	}
+	// an unnamed or blank parameter writes nothing
+	if writer == nil || writer.Name == "_" {
+		return false
+	}
+
+	h.Paths = f.paths(body, writer.Name, h.Kind)
+	return true
+}
+
+// variables returns the handlers held by the package variables of a
+// declaration, var handleHealth = func(w http.ResponseWriter, r *http.Request) {...}.
+func (f *finder) variables(decl *ast.GenDecl) []Handler {
+	var handlers []Handler
+	if decl.Tok != token.VAR {
+		return nil
+	}
+	for _, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok || len(spec.Values) != len(spec.Names) {
+			continue
+		}
+		for i, name := range spec.Names {
+			lit, ok := ast.Unparen(spec.Values[i]).(*ast.FuncLit)
+			if !ok || name.Name == "_" {
+				continue
+			}
+			h := Handler{
+				File: f.fset.Position(name.Pos()).Filename,
+				Line: f.fset.Position(name.Pos()).Line,
+				Name: name.Name,
+			}
+			if f.function(&h, lit.Type, lit.Body) {
+				handlers = append(handlers, h)
+			}
+		}
+	}
+	return handlers
+}
+
+// registrars are the methods registering a handler as their last argument:
+// net/http's (and gin's) Handle and HandleFunc, and the routes of a gin engine
+// or of the RouterGroup its Group returns.
+var registrars = map[string]bool{
+	"HandleFunc": true,
+	"Handle":     true,
+	"GET":        true,
+	"POST":       true,
+	"PUT":        true,
+	"PATCH":      true,
+	"DELETE":     true,
+	"HEAD":       true,
+	"OPTIONS":    true,
+	"Any":        true,
+}
+
+// registered returns the func literal handlers a function body registers:
+// mux.HandleFunc("/x", func(...) {...}), mux.Handle("/x",
+// http.HandlerFunc(func(...) {...})), r.POST("/x", func(c *gin.Context) {...})
+// or api.GET(...) on a group. The string literals before the handler make its
+// route, gin's Handle takes the method first.
+func (f *finder) registered(body *ast.BlockStmt, registrar string) []Handler {
+	var handlers []Handler
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok || !registrars[sel.Sel.Name] {
+			return true
+		}
+
+		last := ast.Unparen(call.Args[len(call.Args)-1])
+		// a conversion, http.HandlerFunc(func(...) {...})
+		if conv, ok := last.(*ast.CallExpr); ok && len(conv.Args) == 1 {
+			last = ast.Unparen(conv.Args[0])
+		}
+		lit, ok := last.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+
+		var route []string
+		for _, arg := range call.Args[:len(call.Args)-1] {
+			if basic, ok := arg.(*ast.BasicLit); ok && basic.Kind == token.STRING {
+				if s, err := strconv.Unquote(basic.Value); err == nil {
+					route = append(route, s)
+				}
+			}
+		}
+
+		pos := f.fset.Position(lit.Pos())
+		h := Handler{
+			File:      pos.Filename,
+			Line:      pos.Line,
+			Registrar: registrar,
+			Route:     strings.Join(route, " "),
+		}
+		h.Name = routeName(h.Route)
+		if h.Name == "" {
+			h.Name = registrar + "Line" + strconv.Itoa(pos.Line)
+		}
+		if f.function(&h, lit.Type, lit.Body) {
+			handlers = append(handlers, h)
+		}
+		return true
+	})
+	return handlers
+}
+
+// routeName makes a name of the words of a route: "POST /users/{id}" is postUsersId.
+func routeName(route string) string {
+	words := strings.FieldsFunc(route, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			word = exported(word)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
+
+// paths finds the error-handling blocks of a body, if x != nil { ... }, and the
+// response each one writes. Function literals are left out, they don't run as
+// part of the handler.
+func (f *finder) paths(body *ast.BlockStmt, writer, kind string) []Path {
+	// the statement before each statement, where the checked error usually comes from
+	previous := map[ast.Stmt]ast.Stmt{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		}
+		for i := 1; i < len(list); i++ {
+			previous[list[i]] = list[i-1]
+		}
+		return true
+	})
+
+	var paths []Path
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			checked := f.checkedError(n.Cond)
+			if checked == nil {
+				return true
+			}
+			p := Path{
+				Line: f.fset.Position(n.Pos()).Line,
+				Call: f.source(checked, n.Init, previous[n]),
+			}
+			f.response(n.Body, writer, kind, &p)
+			paths = append(paths, p)
+		}
+		return true
+	})
+	return paths
+}
+
+// checkedError returns the expression an x != nil condition checks, when it
+// is an error or its type is unknown.
+func (f *finder) checkedError(cond ast.Expr) ast.Expr {
+	bin, ok := ast.Unparen(cond).(*ast.BinaryExpr)
+	if !ok || bin.Op != token.NEQ {
+		return nil
+	}
+
+	x := bin.X
+	if isNil(x) {
+		x = bin.Y
+	} else if !isNil(bin.Y) {
+		return nil
+	}
+
+	t := f.pkg.Info.TypeOf(x)
+	if t == nil || t == types.Typ[types.Invalid] {
+		return x
+	}
+	if types.Implements(t, errorInterface) && types.IsInterface(t) {
+		return x
+	}
+	return nil
+}
+
+func isNil(expr ast.Expr) bool {
+	ident, ok := ast.Unparen(expr).(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+// source returns the call that produced a checked error: the call itself, or
+// the call assigned to it in the if statement or the statement before.
+func (f *finder) source(checked ast.Expr, stmts ...ast.Stmt) string {
+	if call, ok := ast.Unparen(checked).(*ast.CallExpr); ok {
+		return types.ExprString(call.Fun)
+	}
+	name := types.ExprString(checked)
+
+	for _, stmt := range stmts {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || len(assign.Rhs) != 1 {
+			continue
+		}
+		for _, lhs := range assign.Lhs {
+			if types.ExprString(lhs) != name {
+				continue
+			}
+			if call, ok := ast.Unparen(assign.Rhs[0]).(*ast.CallExpr); ok {
+				return types.ExprString(call.Fun)
+			}
+		}
+	}
+	return ""
+}
+
+// response fills in the status and message of the first response a block
+// writes: a statement of the block itself, or else anywhere below it.
+func (f *finder) response(block *ast.BlockStmt, writer, kind string, p *Path) {
+	for _, stmt := range block.List {
+		if expr, ok := stmt.(*ast.ExprStmt); ok && f.write(expr.X, block, writer, kind, p) {
+			return
+		}
+	}
+
+	ast.Inspect(block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if f.write(n, block, writer, kind, p) {
+				return false
+			}
+		}
+		return !p.Writes
+	})
+}
+
+// write recognizes a call writing a response and records it in p.
+func (f *finder) write(expr ast.Expr, block *ast.BlockStmt, writer, kind string, p *Path) bool {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	arg := func(i int) ast.Expr {
+		if i < len(call.Args) {
+			return call.Args[i]
+		}
+		return nil
+	}
+
+	var status, body ast.Expr
+	switch {
+	case kind == "gin" && x.Name == writer:
+		switch sel.Sel.Name {
+		case "JSON", "IndentedJSON", "PureJSON", "SecureJSON", "AsciiJSON", "JSONP",
+			"AbortWithStatusJSON", "AbortWithStatusPureJSON", "XML", "YAML", "TOML", "String", "Data":
+			status, body = arg(0), arg(1)
+		case "AbortWithStatus", "AbortWithError", "Status":
+			status = arg(0)
+		default:
+			return false
+		}
+	case kind == "http" && x.Name == f.imports["net/http"] && sel.Sel.Name == "Error":
+		if first, ok := arg(0).(*ast.Ident); !ok || first.Name != writer {
+			return false
+		}
+		status, body = arg(2), arg(1)
+	case kind == "http" && x.Name == writer && sel.Sel.Name == "WriteHeader":
+		status, body = arg(0), f.written(block, writer)
+	default:
+		return false
+	}
+
+	p.Writes = true
+	if status != nil {
+		p.StatusExpr = types.ExprString(status)
+		p.Status = f.status(status)
+	}
+	p.Message = f.message(body)
+	return true
+}
+
+// written returns what a block writes to w after w.WriteHeader: the argument of
+// fmt.Fprint*(w, ...), io.WriteString(w, ...) or w.Write([]byte(...)).
+func (f *finder) written(block *ast.BlockStmt, writer string) ast.Expr {
+	var found ast.Expr
+	ast.Inspect(block, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found != nil {
+			return found == nil
+		}
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, _ := sel.X.(*ast.Ident)
+		switch {
+		case x != nil && x.Name == writer && sel.Sel.Name == "Write" && len(call.Args) == 1:
+			if conv, ok := call.Args[0].(*ast.CallExpr); ok && len(conv.Args) == 1 {
+				found = conv.Args[0]
+			}
+		case x != nil && (x.Name == f.imports["fmt"] && strings.HasPrefix(sel.Sel.Name, "Fprint") ||
+			x.Name == f.imports["io"] && sel.Sel.Name == "WriteString") && len(call.Args) >= 2:
+			if w, ok := call.Args[0].(*ast.Ident); ok && w.Name == writer {
+				found = call.Args[1]
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+// status returns a status code as a test can write it: a literal, a net/http
+// constant, or the value of a constant. It is "" for anything else.
+func (f *finder) status(expr ast.Expr) string {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		if expr.Kind == token.INT {
+			return expr.Value
+		}
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && x.Name == f.imports["net/http"] && strings.HasPrefix(expr.Sel.Name, "Status") {
+			return "http." + expr.Sel.Name
+		}
+	}
+	if tv, ok := f.pkg.Info.Types[expr]; ok && tv.Value != nil && tv.Value.Kind() == constant.Int {
+		return tv.Value.ExactString()
+	}
+	return ""
+}
+
+// message returns the literal message of a response body: a string literal, or
+// the first string value of a map or struct literal (gin.H{"error": "..."}),
+// preferring the error and message keys.
+func (f *finder) message(expr ast.Expr) string {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		if expr.Kind == token.STRING {
+			if s, err := strconv.Unquote(expr.Value); err == nil {
+				return s
+			}
+		}
+	case *ast.CompositeLit:
+		var first string
+		for _, elt := range expr.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			value := f.message(kv.Value)
+			if value == "" {
+				continue
+			}
+			key := strings.Trim(types.ExprString(kv.Key), "\"`")
+			if key == "error" || key == "message" || key == "Error" || key == "Message" {
+				return value
+			}
+			if first == "" {
+				first = value
+			}
+		}
+		return first
+	case *ast.CallExpr:
+		// []byte("..."), errors.New("...")
+		if len(expr.Args) == 1 {
+			return f.message(expr.Args[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/golden"
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// TestHandlers finds the handlers of each package of testdata/src, and compares
+// them with testdata/<package>.json and the tests generated for each source file
+// with testdata/<package>/<test file>.golden.
+func TestHandlers(t *testing.T) {
+	// the fixtures import gin, which isn't a requirement: go list must not add it
+	t.Setenv("GOFLAGS", "-mod=readonly")
+	t.Setenv("GOPROXY", "off")
+
+	for _, name := range golden.Cases(t) {
+		t.Run(name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			dir, err := filepath.Abs(filepath.Join("testdata", "src", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			packages, err := load.Packages(fset, []string{dir}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handlers := []Handler{}
+			for _, pkg := range packages {
+				var sources []string
+				bySource := map[string][]Handler{}
+				for _, h := range Handlers(fset, pkg, nil) {
+					if _, ok := bySource[h.File]; !ok {
+						sources = append(sources, h.File)
+					}
+					bySource[h.File] = append(bySource[h.File], h)
+				}
+
+				for _, source := range sources {
+					src, err := Generate(pkg.Name, source, bySource[source])
+					if err != nil {
+						t.Fatal(err)
+					}
+					golden.Check(t, filepath.Join("testdata", name, filepath.Base(TestFile(source))+".golden"), src)
+
+					// paths relative to the package, the golden files don't depend on the checkout
+					for _, h := range bySource[source] {
+						h.File = filepath.ToSlash(load.Relative(dir, h.File))
+						handlers = append(handlers, h)
+					}
+				}
+			}
+
+			out, err := json.MarshalIndent(handlers, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, filepath.Join("testdata", name+".json"), append(out, '\n'))
+		})
+	}
+}
+
+func TestTestFile(t *testing.T) {
+	if got, want := TestFile("/src/api/handlers.go"), "/src/api/handlers_errpaths_test.go"; got != want {
+		t.Errorf("TestFile = %q, want %q", got, want)
+	}
+}
+
+func TestRouteName(t *testing.T) {
+	tests := []struct {
+		route string
+		want  string
+	}{
+		{"/users", "users"},
+		{"GET /users/{id}", "getUsersId"},
+		{"POST /v2/orders/{id...}", "postV2OrdersId"},
+		{"/", ""},
+	}
+
+	for _, tt := range tests {
+		if got := routeName(tt.route); got != tt.want {
+			t.Errorf("routeName(%q) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Command no-go-httptest writes table-driven httptest skeletons for the error
+// paths of gin and net/http handlers. For each handler it finds the
+// error-handling blocks (if err != nil { ... }) and the status and message each
+// one responds with, and writes a test with one case per block, the expected
+// status and body filled in and the request body left to write.
+//
+// Handlers are functions or methods taking a *gin.Context, or an
+// http.ResponseWriter and an *http.Request, package variables holding such a
+// func literal, and func literals registered with HandleFunc or Handle or on a
+// gin router or group, r.POST("/x", func(c *gin.Context) { ... }) (their test
+// has a handler variable to set, named after the route). The tests of
+// handlers.go go to handlers_errpaths_test.go, in the same package.
+//
+// It only needs the standard library.
+//
+// Usage:
+//
+//	no-go-httptest [flags] [packages]
+//
+// Packages are directories, dir/... includes everything below dir. The default
+// is the current directory. Without -w the tests are printed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+var (
+	writeFlag = flag.Bool("w", false, "write the tests next to the handlers instead of printing them")
+	forceFlag = flag.Bool("force", false, "with -w, overwrite test files that already exist")
+	funcsFlag = flag.String("funcs", "", "comma separated handlers to generate tests for, all when empty (* matches a prefix), func literals match by route name or by the function registering them")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: no-go-httptest [flags] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	fset := token.NewFileSet()
+	packages, err := load.Packages(fset, patterns, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no-go-httptest: %v\n", err)
+		os.Exit(2)
+	}
+
+	cwd, _ := os.Getwd()
+	status := 0
+	for _, pkg := range packages {
+		// the handlers of each source file, in order
+		var sources []string
+		bySource := map[string][]Handler{}
+		for _, h := range Handlers(fset, pkg, load.List(*funcsFlag)) {
+			if len(h.Paths) == 0 {
+				continue
+			}
+			if _, ok := bySource[h.File]; !ok {
+				sources = append(sources, h.File)
+			}
+			bySource[h.File] = append(bySource[h.File], h)
+		}
+
+		for _, source := range sources {
+			src, err := Generate(pkg.Name, source, bySource[source])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "no-go-httptest: %v\n", err)
+				os.Exit(2)
+			}
+
+			file := TestFile(source)
+			if !*writeFlag {
+				fmt.Printf("// ==> %s <==\n%s\n", load.Relative(cwd, file), src)
+				continue
+			}
+
+			if _, err := os.Stat(file); err == nil && !*forceFlag {
+				fmt.Fprintf(os.Stderr, "no-go-httptest: %s exists, -force overwrites it\n", load.Relative(cwd, file))
+				status = 1
+				continue
+			} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "no-go-httptest: %v\n", err)
+				os.Exit(2)
+			}
+			if err := os.WriteFile(file, src, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "no-go-httptest: %v\n", err)
+				os.Exit(2)
+			}
+			fmt.Println(load.Relative(cwd, file))
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type request struct {
+	Name string `json:"name"`
+}
+
+type Users struct{}
+
+func (u *Users) Create(c *gin.Context) {
+	var req request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	user, err := save(req.Name)
+	if err != nil {
+		c.AbortWithStatus(500)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+func save(name string) (string, error) {
+	return name, nil
+}
+
+func router() *gin.Engine {
+	r := gin.Default()
+	r.POST("/process", func(c *gin.Context) {
+		if err := c.ShouldBindJSON(&request{}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+	})
+
+	api := r.Group("/api")
+	api.Handle("DELETE", "/users/:id", func(c *gin.Context) {
+		if _, err := save(c.Param("id")); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// handlers registered by name are found as functions
+	r.GET("/users", (&Users{}).Create)
+	return r
+}
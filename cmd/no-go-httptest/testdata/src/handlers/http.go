@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const statusTeapot = 418
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	if err := ping(); err != nil {
+		w.WriteHeader(statusTeapot)
+		fmt.Fprint(w, "no tea")
+		return
+	}
+
+	if err := ping(); err != nil {
+		return
+	}
+}
+
+// not a handler, the writer is blank
+func Ignored(_ http.ResponseWriter, r *http.Request) {}
+
+func ping() error {
+	return nil
+}
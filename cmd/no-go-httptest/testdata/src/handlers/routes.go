@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+)
+
+var errMissing = errors.New("missing")
+
+// a package variable, tests call it by name
+var handleVersion = func(w http.ResponseWriter, r *http.Request) {
+	if err := check(r); err != nil {
+		http.Error(w, "no version", http.StatusNotFound)
+		return
+	}
+}
+
+func routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			http.Error(w, "unknown user", http.StatusNotFound)
+			return
+		}
+	})
+
+	mux.Handle("/orders", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}))
+
+	// not a handler
+	mux.HandleFunc("/noop", func(s string) {})
+}
+
+func check(r *http.Request) error {
+	if r.URL.Path == "" {
+		return errMissing
+	}
+	return nil
+}
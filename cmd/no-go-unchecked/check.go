@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// Finding is an error result that is never checked.
+type Finding struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	// Kind is dropped (the call is a statement), blank (assigned to _) or
+	// overwritten (assigned, then assigned again before any read)
+	Kind    string `json:"kind"`
+	Call    string `json:"call"`
+	Message string `json:"message"`
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func isError(t types.Type) bool {
+	return t != nil && types.Identical(t, errorType)
+}
+
+// event is an assignment to or a read of a variable, in source order.
+type event struct {
+	pos    token.Pos
+	assign bool
+	// call is set when the assigned value is the error result of a call
+	call *ast.CallExpr
+	// owner is the block (or case clause) holding the assignment
+	owner ast.Node
+}
+
+type checker struct {
+	fset *token.FileSet
+	pkg  *load.Package
+	// funcs are the calls assumed to return an error when their type is unknown
+	funcs []string
+	// exclude are the calls whose error may be dropped, by qualified name
+	exclude  []string
+	findings []Finding
+	events   map[types.Object][]event
+	// lhs are the identifiers assigned to, they are not reads
+	lhs map[*ast.Ident]bool
+}
+
+// Check reports the unchecked error results of a package.
+func Check(fset *token.FileSet, pkg *load.Package, funcs, exclude []string) []Finding {
+	c := &checker{
+		fset:    fset,
+		pkg:     pkg,
+		funcs:   funcs,
+		exclude: exclude,
+		events:  map[types.Object][]event{},
+		lhs:     map[*ast.Ident]bool{},
+	}
+
+	for _, file := range pkg.Files {
+		c.walk(file)
+	}
+	for ident, obj := range pkg.Info.Uses {
+		if !c.lhs[ident] {
+			if _, ok := obj.(*types.Var); ok {
+				c.events[obj] = append(c.events[obj], event{pos: ident.Pos()})
+			}
+		}
+	}
+	c.overwritten()
+
+	sort.Slice(c.findings, func(i, j int) bool {
+		a, b := c.findings[i], c.findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return c.findings
+}
+
+func (c *checker) walk(file *ast.File) {
+	var stack []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+
+		var parent ast.Node
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			if call, ok := ast.Unparen(n.X).(*ast.CallExpr); ok && !c.excluded(call) {
+				if results, _ := c.errorResults(call); len(results) > 0 {
+					c.report(call, "dropped", "error returned by %s is not checked")
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN || n.Tok == token.DEFINE {
+				c.assign(n.Lhs, n.Rhs, n.End(), parent)
+			}
+		case *ast.ValueSpec:
+			names := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				names[i] = name
+			}
+			c.assign(names, n.Values, n.End(), parent)
+		}
+
+		stack = append(stack, n)
+		return true
+	})
+}
+
+// assign records the assignments of lhs = rhs, and reports error results assigned to _.
+func (c *checker) assign(lhs, rhs []ast.Expr, end token.Pos, owner ast.Node) {
+	// errors[i] is the call whose error result lands in lhs[i]
+	errors := make([]*ast.CallExpr, len(lhs))
+
+	if len(rhs) == 1 && len(lhs) > 1 {
+		if call, ok := ast.Unparen(rhs[0]).(*ast.CallExpr); ok && !c.excluded(call) {
+			results, known := c.errorResults(call)
+			for _, i := range results {
+				if !known {
+					// the type is unknown, assume the error comes last
+					i = len(lhs) - 1
+				}
+				if i < len(lhs) {
+					errors[i] = call
+				}
+			}
+		}
+	} else if len(rhs) == len(lhs) {
+		for i, value := range rhs {
+			if call, ok := ast.Unparen(value).(*ast.CallExpr); ok && !c.excluded(call) {
+				if results, _ := c.errorResults(call); len(results) > 0 {
+					errors[i] = call
+				}
+			}
+		}
+	}
+
+	for i, expr := range lhs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		c.lhs[ident] = true
+
+		if ident.Name == "_" {
+			if errors[i] != nil {
+				c.report(errors[i], "blank", "error returned by %s is assigned to _")
+			}
+			continue
+		}
+
+		obj := c.pkg.Info.Defs[ident]
+		if obj == nil {
+			obj = c.pkg.Info.Uses[ident]
+		}
+		if obj != nil {
+			c.events[obj] = append(c.events[obj], event{pos: end, assign: true, call: errors[i], owner: owner})
+		}
+	}
+}
+
+// overwritten reports the error results assigned to a variable that is assigned
+// again, in the same block, before anything reads it. Assignments in different
+// blocks (if/else branches) are left alone since either may run.
+func (c *checker) overwritten() {
+	for _, events := range c.events {
+		sort.Slice(events, func(i, j int) bool {
+			return events[i].pos < events[j].pos
+		})
+
+		for i := 0; i+1 < len(events); i++ {
+			current, next := events[i], events[i+1]
+			if current.call != nil && next.assign && next.owner == current.owner {
+				c.report(current.call, "overwritten", "error returned by %s is overwritten before it is checked")
+			}
+		}
+	}
+}
+
+// errorResults returns the indexes of the error results of a call. known is false
+// when the type of the call is unknown and it is assumed to return an error
+// because it is listed in -funcs.
+func (c *checker) errorResults(call *ast.CallExpr) (results []int, known bool) {
+	// conversions look like calls
+	if fun, ok := c.pkg.Info.Types[call.Fun]; ok && fun.IsType() {
+		return nil, true
+	}
+
+	tv, ok := c.pkg.Info.Types[call]
+	if ok && tv.Type != nil && tv.Type != types.Typ[types.Invalid] {
+		switch t := tv.Type.(type) {
+		case *types.Tuple:
+			for i := 0; i < t.Len(); i++ {
+				if isError(t.At(i).Type()) {
+					results = append(results, i)
+				}
+			}
+		default:
+			if isError(t) {
+				results = append(results, 0)
+			}
+		}
+		return results, true
+	}
+
+	name, short := c.callNames(call)
+	if load.Matches(name, c.funcs) || load.Matches(short, c.funcs) {
+		return []int{0}, false
+	}
+	return nil, false
+}
+
+// callNames returns the call as written (c.ShouldBindJSON) and the name of the
+// function or method alone (ShouldBindJSON).
+func (c *checker) callNames(call *ast.CallExpr) (name, short string) {
+	name = types.ExprString(call.Fun)
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		short = fun.Sel.Name
+	case *ast.Ident:
+		short = fun.Name
+	}
+	return name, short
+}
+
+// excluded reports whether the call may drop its error, by its qualified name
+// (fmt.Println, (*strings.Builder).WriteString) or as written.
+func (c *checker) excluded(call *ast.CallExpr) bool {
+	var ident *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	case *ast.Ident:
+		ident = fun
+	}
+	if ident != nil {
+		if fn, ok := c.pkg.Info.Uses[ident].(*types.Func); ok && load.Matches(fn.FullName(), c.exclude) {
+			return true
+		}
+	}
+
+	name, _ := c.callNames(call)
+	return load.Matches(name, c.exclude)
+}
+
+func (c *checker) report(call *ast.CallExpr, kind, format string) {
+	name, _ := c.callNames(call)
+	pos := c.fset.Position(call.Pos())
+	c.findings = append(c.findings, Finding{
+		File:    pos.Filename,
+		Line:    pos.Line,
+		Col:     pos.Column,
+		Kind:    kind,
+		Call:    name,
+		Message: fmt.Sprintf(format, name),
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/golden"
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+// TestCheck runs the checker on each package of testdata/src with the default
+// flags, and compares the JSON output with testdata/<package>.json.
+func TestCheck(t *testing.T) {
+	for _, name := range golden.Cases(t) {
+		t.Run(name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			dir := filepath.Join("testdata", "src", name)
+			packages, err := load.Packages(fset, []string{dir}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			findings := []Finding{}
+			for _, pkg := range packages {
+				for _, finding := range Check(fset, pkg, load.List(*funcsFlag), load.List(*excludeFlag)) {
+					// paths relative to the package, the golden files don't depend on the checkout
+					rel, err := filepath.Rel(abs, finding.File)
+					if err != nil {
+						t.Fatal(err)
+					}
+					finding.File = filepath.ToSlash(rel)
+					findings = append(findings, finding)
+				}
+			}
+
+			var out bytes.Buffer
+			if err := encode(&out, findings); err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, filepath.Join("testdata", name+".json"), out.Bytes())
+		})
+	}
+}
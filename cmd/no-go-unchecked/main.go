@@ -0,0 +1,81 @@
+// Command no-go-unchecked reports calls whose error result is never checked:
+// the call is a statement of its own, its error is assigned to _, or its error
+// is assigned to a variable that is assigned again before anything reads it.
+//
+// It only needs the standard library. Packages whose imports can't be type
+// checked (a dependency that isn't downloaded) are still analyzed, calls of
+// unknown type are assumed to return an error when they are listed in -funcs.
+//
+// Usage:
+//
+//	no-go-unchecked [flags] [packages]
+//
+// Packages are directories, dir/... includes everything below dir. The default
+// is the current directory. The exit status is 1 when something is reported.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/TheNoeTrevino/no-go.nvim/cmd/internal/load"
+)
+
+var (
+	jsonFlag    = flag.Bool("json", false, "print the findings as a JSON array")
+	testsFlag   = flag.Bool("tests", true, "analyze _test.go files too")
+	funcsFlag   = flag.String("funcs", "ShouldBind*", "comma separated calls assumed to return an error when their type is unknown (c.ShouldBindJSON, or ShouldBindJSON for any receiver, * matches a prefix)")
+	excludeFlag = flag.String("exclude", "fmt.Print*,fmt.Fprint*,(*bytes.Buffer).Write*,(*strings.Builder).Write*", "comma separated calls that may drop their error, by qualified name or as written (* matches a prefix)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: no-go-unchecked [flags] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	fset := token.NewFileSet()
+	packages, err := load.Packages(fset, patterns, *testsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no-go-unchecked: %v\n", err)
+		os.Exit(2)
+	}
+
+	findings := []Finding{}
+	for _, pkg := range packages {
+		findings = append(findings, Check(fset, pkg, load.List(*funcsFlag), load.List(*excludeFlag))...)
+	}
+
+	if *jsonFlag {
+		if err := encode(os.Stdout, findings); err != nil {
+			fmt.Fprintf(os.Stderr, "no-go-unchecked: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		cwd, _ := os.Getwd()
+		for _, finding := range findings {
+			fmt.Printf("%s:%d:%d: %s\n", load.Relative(cwd, finding.File), finding.Line, finding.Col, finding.Message)
+		}
+	}
+
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// encode writes the findings as an indented JSON array.
+func encode(w io.Writer, findings []Finding) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(findings)
+}
@@ -0,0 +1,56 @@
+package unchecked
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func open() error { return errors.New("open") }
+
+func read() (int, error) { return 0, nil }
+
+func dropped() {
+	open()
+	os.Remove("file")
+	_ = open()
+	_, _ = read()
+
+	// excluded by default
+	fmt.Println("fine")
+	var b strings.Builder
+	b.WriteString("fine")
+}
+
+func overwritten() error {
+	err := open()
+	err = open()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func readBetween() error {
+	err := open()
+	fmt.Println(err)
+	err = open()
+	return err
+}
+
+func branches(ok bool) error {
+	var err error
+	if ok {
+		err = open()
+	} else {
+		err = open()
+	}
+	return err
+}
+
+// the type of c is unknown, ShouldBind* is assumed to return an error
+func unknown(c binder) {
+	c.ShouldBindJSON(nil)
+	c.Header("fine")
+}
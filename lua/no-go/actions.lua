@@ -0,0 +1,81 @@
+local M = {}
+
+local sentinel = require("no-go.sentinel")
+
+-- the code actions offered on a row, each a function(bufnr, row) -> code action|nil
+M.providers = {
+	sentinel.action,
+}
+
+--- The code actions of a textDocument/codeAction request
+--- @param params table The request parameters
+--- @return table The code actions
+function M.code_actions(params)
+	local bufnr = vim.uri_to_bufnr(params.textDocument.uri)
+	local row = params.range.start.line
+
+	local actions = {}
+	for _, provider in ipairs(M.providers) do
+		local ok, action = pcall(provider, bufnr, row)
+		if not ok then
+			vim.notify("no-go.nvim: Code action failed: " .. tostring(action), vim.log.levels.WARN)
+		elseif action then
+			table.insert(actions, action)
+		end
+	end
+	return actions
+end
+
+--- Start the in-process language server, what vim.lsp.start runs in place of a command
+--- It only answers initialize, shutdown and textDocument/codeAction
+--- @param dispatchers vim.lsp.rpc.Dispatchers The client's callbacks
+--- @return vim.lsp.rpc.PublicClient The server, as the client sees it
+function M.server(dispatchers)
+	local closing = false
+	local request_id = 0
+
+	return {
+		request = function(method, params, callback)
+			request_id = request_id + 1
+			if method == "initialize" then
+				callback(nil, {
+					capabilities = { codeActionProvider = { codeActionKinds = { "refactor.extract" } } },
+					serverInfo = { name = "no-go" },
+				})
+			elseif method == "textDocument/codeAction" then
+				callback(nil, M.code_actions(params))
+			else
+				callback(nil, nil)
+			end
+			return true, request_id
+		end,
+		notify = function(method)
+			if method == "exit" then
+				closing = true
+				dispatchers.on_exit(0, 15)
+			end
+			return true
+		end,
+		is_closing = function()
+			return closing
+		end,
+		terminate = function()
+			closing = true
+		end,
+	}
+end
+
+--- Attach the in-process server to a Go buffer, one client per module
+--- @param bufnr number The buffer number
+--- @param root string The module root
+function M.attach(bufnr, root)
+	vim.lsp.start({
+		name = "no-go",
+		cmd = M.server,
+		root_dir = root,
+		-- columns come from treesitter, in bytes
+		offset_encoding = "utf-8",
+	}, { bufnr = bufnr })
+end
+
+return M
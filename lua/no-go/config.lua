@@ -8,6 +8,24 @@ M.defaults = {
 	-- identifiers to match in if statements (e.g., "if err != nil", "if error != nil")
 	identifiers = { "err" },
 
+	-- what has to end an error handling block for it to collapse
+	-- "return" matches a return anywhere in the block, anything else has to be its last statement:
+	-- "continue", "break", "goto" or a call like "panic", "os.Exit" ("log.Fatal*" matches by prefix)
+	terminators = { "return" },
+
+	-- the web or RPC framework the code uses, adds the calls that end its handlers' error blocks
+	-- to terminators: "gin" or "net/http" (see M.profiles), nil for none
+	profile = nil,
+
+	-- what to do with the blocks: "collapse" them, "show" them, or "dim" them (NoGoDimmed over the body)
+	-- mostly useful per file, see directive_lines
+	mode = "collapse",
+
+	-- file directives: a comment within the first lines of a Go file sets options for that file,
+	-- over the project file (// no-go: identifiers=e,err2 mode=dim fold_imports=true)
+	-- read when the buffer is first configured and again on write, 0 to ignore them
+	directive_lines = 10,
+
 	-- virtual text structure for a collapsed error handling block
 	-- formatted will be: prefix + content + content_separator + return_character + suffix
 	virtual_text = {
@@ -15,6 +33,9 @@ M.defaults = {
 		content_separator = " ",
 		return_character = "󱞿 ",
 		suffix = "",
+		-- shorten the text when it doesn't fit after the if line: content + return_character,
+		-- then return_character alone. re-evaluated when windows are resized or scrolled
+		adaptive = true,
 	},
 
 	-- virtual text for collapsed import blocks
@@ -26,6 +47,13 @@ M.defaults = {
 	-- enable import folding
 	fold_imports = false,
 
+	-- collapse lazy initialisation: if m.cache == nil { m.cache = make(...) } shows as m.cache ??= make(...)
+	fold_lazy_init = false,
+
+	-- collapse test assertions in _test.go files: if got != want { t.Errorf(...) } shows as assert got == want,
+	-- assert! when the call is t.Fatal*
+	fold_assertions = false,
+
 	highlight_group = "NoGoZone",
 
 	highlight = {
@@ -52,23 +80,427 @@ M.defaults = {
 		down = "j",
 		up = "k",
 	},
+
+	-- mouse support on the inline marker of a collapsed block
+	-- toggle: clicking the marker reveals the block (and collapses it again)
+	-- peek: hovering the marker shows the hidden lines in a float, needs 'mousemoveevent'
+	-- clicks and moves anywhere else behave as usual
+	-- the mappings are local to the Go buffers no-go runs in, and removed when it's turned off
+	mouse = {
+		enabled = false,
+		toggle = "<LeftMouse>",
+		peek = "<MouseMove>",
+	},
+
+	-- debugger integration, works with any plugin that places signs (nvim-dap by default)
+	-- signs in reveal_sign_groups reveal the block hiding them (nvim-dap puts dap_pc on the stopped line)
+	-- signs in mark_sign_groups add the indicator to the collapsed line instead
+	-- stops sent with the NoGoDebugStop User event always reveal their block
+	-- off by default, it polls the signs of the visible Go buffers (stopped while no-go is turned off)
+	debugger = {
+		enabled = false,
+		reveal_sign_groups = { "dap_pc" },
+		mark_sign_groups = { "dap_breakpoints" },
+		indicator = " ●",
+		-- signs have no autocmd, so they are polled (ms)
+		poll_interval = 500,
+	},
+
+	-- with the cursor on an error guard, highlight where the tested identifier is assigned,
+	-- checked and returned in the function (NoGoErrLifecycle), and assignments that are
+	-- overwritten before any check (NoGoErrUnchecked)
+	lifecycle = true,
+
+	-- :NoGoTestResults, blocks hit by failing tests are revealed and list the tests after this prefix
+	test_results = {
+		prefix = "  ✗ ",
+	},
+
+	-- :NoGoUnchecked and :NoGoErrStyle run the Go tools in cmd/ and mark the calls whose error
+	-- is never checked, and the error strings that break the conventions
+	-- findings on lines hidden by a block are shown on its collapsed line
+	-- unchecked, errstyle: the commands to run, default to the binaries when installed
+	-- (cd cmd && go install ./...), or `go run` from the plugin directory
+	lint = {
+		prefix = "  ⚠ ",
+		unchecked = nil, -- { "no-go-unchecked", "-funcs", "ShouldBind*,Decode" }
+		errstyle = nil, -- { "no-go-errstyle", "-tests=false" }
+	},
+
+	-- adaptive collapsing: count how often each block is revealed by hand (not by the cursor) in a
+	-- file under stdpath("state"), nothing leaves the machine. blocks revealed `threshold` times stop
+	-- collapsing: "expand" leaves them open, "dim" leaves them open with NoGoFrequent over the body
+	-- :NoGoResetLearning forgets the counts
+	adaptive = {
+		enabled = false,
+		threshold = 5,
+		action = "dim",
+	},
+
+	-- a narrow float on the right edge of Go windows with a mark for each block and lint finding,
+	-- placed in proportion to the whole file, and the visible part highlighted like a scrollbar
+	-- follows scrolling and resizing, clicking it (with mouse.toggle) jumps to the mark on that row
+	scrollbar = {
+		enabled = false,
+		width = 1,
+		collapsed = "━",
+		revealed = "─",
+		lint = "▲",
+	},
+
+	-- :NoGoMargin, a window beside the current one showing the hidden lines of each collapsed block
+	-- next to its if line
+	margin = {
+		width = 60,
+	},
+
+	-- disclosure levels, stepped with :NoGoCycle: "hidden", "summary" (a virtual line with the body on one line),
+	-- "dimmed" (open, noise statements in NoGoNoise) and "expanded"
+	disclosure = {
+		-- the level blocks start at, by category (e.g. { assert = "summary" }), "hidden" when missing
+		default = {},
+		-- calls dimmed at the "dimmed" level, "*" at the end matches by prefix
+		noise = { "log.Print*", "log.Debug*", "slog.*", "fmt.Print*", "fmt.Fprint*", "logger.*" },
+		summary_prefix = "↳ ",
+		summary_width = 80,
+	},
+
+	-- code actions from an in-process language server (vim.lsp.buf.code_action), on the block under the cursor:
+	-- extract an errors.New literal into a package sentinel
+	code_actions = {
+		enabled = false,
+	},
+
+	-- :NoGoDuplicates, blocks with the same shape once literals (and identifiers) are ignored
+	duplicates = {
+		literals = true,
+		identifiers = false,
+		-- smaller bodies (a lone return err) are left out
+		min_statements = 2,
+	},
+
+	-- :NoGoReview, the marks are kept in this file at the module root, by block anchor
+	review = {
+		file = ".no-go-review.json",
+	},
+
+	-- matcher categories to start with, category -> false to turn it off
+	-- (built-in: "error", "import", registered matchers add theirs), :NoGoCategoryToggle flips one
+	categories = {},
+
+	-- buffers no-go leaves alone, whatever the global state or buffer overrides
+	-- skip: function(bufnr) returning true (or a reason string) to skip the buffer
+	skip = nil,
+
+	-- Lua patterns matched against the full path, matching buffers are disabled unless
+	-- enabled with :NoGoBufEnable (e.g. "_test%.go$", "/mocks/")
+	exclude = {},
 }
 
 -- current configuration (will be merged with user config)
 M.options = {}
 
+-- project configuration file, looked up from the buffer's directory upwards
+-- (`:NoGoLearn` writes one)
+M.project_file = ".no-go.json"
+
+-- the options a project file can set, it comes with the checkout and is only read once trusted
+M.project_keys = { identifiers = true, terminators = true, profile = true }
+
+-- the terminators each profile adds. echo, fiber and grpc handlers return their errors, so
+-- "return" already covers them and they have no profile
+M.profiles = {
+	gin = { terminators = { "c.Abort*" } },
+	["net/http"] = { terminators = { "http.Error", "http.NotFound", "http.Redirect" } },
+}
+
+-- decoded project files, path -> { mtime, options }
+local project_cache = {}
+
+-- file directives, // no-go: key=value key=value
+local directive_pattern = "^%s*//%s*no%-go:%s*(.-)%s*$"
+
+-- the values mode takes
+M.modes = { collapse = true, show = true, dim = true }
+
+-- the options a directive can set: what is matched and how it's displayed, never where
+-- something is written or run, the file may come from a checkout nobody reviewed
+M.directive_keys = {
+	identifiers = true,
+	terminators = true,
+	profile = true,
+	mode = true,
+	fold_imports = true,
+	fold_lazy_init = true,
+	fold_assertions = true,
+	reveal_on_cursor = true,
+	["virtual_text.prefix"] = true,
+	["virtual_text.content_separator"] = true,
+	["virtual_text.return_character"] = true,
+	["virtual_text.suffix"] = true,
+	["virtual_text.adaptive"] = true,
+	["import_virtual_text.prefix"] = true,
+	["import_virtual_text.suffix"] = true,
+}
+
+-- parsed directives per buffer, bufnr -> { options, text }
+local directive_cache = {}
+
+-- resolved configuration per buffer, bufnr -> options, M.get runs on every cursor move
+local resolved_cache = {}
+
 --- Setup configuration by merging user config with defaults
 --- @param user_config table|nil Optional user configuration to override defaults
 --- @return table The merged configuration options
 function M.setup(user_config)
 	M.options = vim.tbl_deep_extend("force", M.defaults, user_config or {})
+	M.invalidate()
 	M.setup_highlight()
 
 	return M.options
 end
 
+--- Read the project configuration file for a buffer
+--- @param bufnr number The buffer number
+--- @return table|nil options The project options, or nil if there is no project file
+--- @return string|nil path The path of the project file
+function M.read_project(bufnr)
+	local name = vim.api.nvim_buf_get_name(bufnr)
+	if name == "" then
+		return nil, nil
+	end
+
+	local path = vim.fs.find(M.project_file, { path = vim.fs.dirname(name), upward = true, type = "file" })[1]
+	if not path then
+		return nil, nil
+	end
+
+	local stat = vim.uv.fs_stat(path)
+	local mtime = stat and (stat.mtime.sec .. "." .. stat.mtime.nsec)
+	local cached = project_cache[path]
+	if cached and cached.mtime == mtime then
+		return cached.options, path
+	end
+
+	-- the file comes with the checkout, :trust (or the prompt) decides if it's read at all
+	local contents = vim.secure.read(path)
+	local options = {}
+	if contents then
+		local ok, decoded = pcall(vim.json.decode, contents)
+		if ok and type(decoded) == "table" then
+			local ignored = {}
+			for key, value in pairs(decoded) do
+				if M.project_keys[key] then
+					options[key] = value
+				else
+					table.insert(ignored, key)
+				end
+			end
+			if #ignored > 0 then
+				table.sort(ignored)
+				vim.notify(
+					"no-go.nvim: Ignoring " .. table.concat(ignored, ", ") .. " in " .. path .. ", set them in setup()",
+					vim.log.levels.WARN
+				)
+			end
+		else
+			vim.notify("no-go.nvim: Can't read " .. path .. ", ignoring it", vim.log.levels.WARN)
+		end
+	end
+
+	project_cache[path] = { mtime = mtime, options = options }
+	return options, path
+end
+
+--- Convert a directive value to the type of the option it sets
+--- only plain options can be set from a file: booleans, numbers, strings and lists of strings,
+--- never commands or functions
+--- @param default any The default value of the option
+--- @param value string The value as written
+--- @return any|nil The converted value, or nil if it doesn't fit the option
+local function convert(default, value)
+	local kind = type(default)
+	if kind == "boolean" then
+		if value == "true" or value == "false" then
+			return value == "true"
+		end
+	elseif kind == "number" then
+		return tonumber(value)
+	elseif kind == "string" then
+		return value
+	elseif kind == "table" and vim.islist(default) and (#default == 0 or type(default[1]) == "string") then
+		return vim.split(value, ",", { trimempty = true })
+	end
+	return nil
+end
+
+--- Parse the directives in the first lines of a buffer (directive_lines)
+--- @param bufnr number The buffer number
+--- @return table|nil options The options they set, or nil without directives
+--- @return string|nil text The directives as written
+function M.read_directives(bufnr)
+	local count = M.options.directive_lines or 0
+	local lines = count > 0 and vim.api.nvim_buf_get_lines(bufnr, 0, count, false) or {}
+
+	local options, texts, problems = {}, {}, {}
+	for lnum, line in ipairs(lines) do
+		local text = line:match(directive_pattern)
+		if text then
+			table.insert(texts, text)
+			for token in text:gmatch("%S+") do
+				local key, value = token:match("^([%w_%.]+)=(.+)$")
+				local default = M.defaults
+				for part in (key or ""):gmatch("[^%.]+") do
+					default = type(default) == "table" and default[part] or nil
+				end
+
+				local converted = nil
+				if key == "profile" then
+					converted = M.profiles[value] and value or nil
+				elseif key and M.directive_keys[key] and default ~= nil then
+					converted = convert(default, value)
+				end
+				if key == "mode" and not M.modes[value] then
+					converted = nil
+				end
+
+				if converted == nil then
+					local reason = key and not M.directive_keys[key] and " (not settable from a file)" or ""
+					table.insert(problems, string.format("line %d: %s%s", lnum, token, reason))
+				else
+					-- dotted keys set nested options, virtual_text.prefix=>
+					local target = options
+					local parts = vim.split(key, ".", { plain = true })
+					for i = 1, #parts - 1 do
+						target[parts[i]] = target[parts[i]] or {}
+						target = target[parts[i]]
+					end
+					target[parts[#parts]] = converted
+				end
+			end
+		end
+	end
+
+	if #problems > 0 then
+		vim.notify(
+			"no-go.nvim: Ignoring directives of "
+				.. vim.fn.fnamemodify(vim.api.nvim_buf_get_name(bufnr), ":t")
+				.. ": "
+				.. table.concat(problems, ", "),
+			vim.log.levels.WARN
+		)
+	end
+
+	local directives = #texts > 0 and { options = options, text = table.concat(texts, " ") } or false
+	directive_cache[bufnr] = directives
+	resolved_cache[bufnr] = nil
+	return directives and directives.options or nil, directives and directives.text or nil
+end
+
+--- The directives of a buffer, read when first asked for
+--- @param bufnr number The buffer number
+--- @return table|nil options The options they set, or nil without directives
+--- @return string|nil text The directives as written
+function M.directives(bufnr)
+	local cached = directive_cache[bufnr]
+	if cached == nil then
+		return M.read_directives(bufnr)
+	end
+	if not cached then
+		return nil, nil
+	end
+	return cached.options, cached.text
+end
+
+--- Forget the directives of a buffer, it was deleted
+--- @param bufnr number The buffer number
+function M.forget_directives(bufnr)
+	directive_cache[bufnr] = nil
+	resolved_cache[bufnr] = nil
+end
+
+--- Forget the resolved configuration of a buffer, or of every buffer
+--- (setup, a project file was written, the working directory changed)
+--- @param bufnr number|nil The buffer number, nil for every buffer
+function M.invalidate(bufnr)
+	if bufnr then
+		resolved_cache[bufnr] = nil
+	else
+		resolved_cache = {}
+	end
+end
+
+--- Merge override options over base options, lists (like identifiers) are replaced, not merged
+--- @param base table The base options
+--- @param override table The options to merge over them
+--- @return table The merged options (a new table)
+function M.merge(base, override)
+	local merged = vim.deepcopy(base)
+	for key, value in pairs(override) do
+		if type(value) == "table" and type(merged[key]) == "table" and not vim.islist(value) then
+			merged[key] = M.merge(merged[key], value)
+		else
+			merged[key] = vim.deepcopy(value)
+		end
+	end
+	return merged
+end
+
+--- The configuration for a buffer: the user config, its project file merged over it,
+--- and the directives of the file over both, resolved once until invalidated
+--- @param bufnr number The buffer number
+--- @return table The options for that buffer
+function M.get(bufnr)
+	if resolved_cache[bufnr] then
+		return resolved_cache[bufnr]
+	end
+
+	local options = M.options
+
+	local project = M.read_project(bufnr)
+	if project and not vim.tbl_isempty(project) then
+		options = M.merge(options, project)
+	end
+
+	local directives = M.directives(bufnr)
+	if directives and not vim.tbl_isempty(directives) then
+		options = M.merge(options, directives)
+	end
+
+	local profile = options.profile and M.profiles[options.profile]
+	if profile then
+		options = vim.deepcopy(options)
+		for _, terminator in ipairs(profile.terminators) do
+			if not vim.tbl_contains(options.terminators, terminator) then
+				table.insert(options.terminators, terminator)
+			end
+		end
+	end
+
+	resolved_cache[bufnr] = options
+	return options
+end
+
 --- Setup the NoGoZone highlight group
 function M.setup_highlight()
+	-- groups for the extra markers, default so colorschemes can set them
+	vim.api.nvim_set_hl(0, "NoGoBreakpoint", { link = "DiagnosticError", default = true })
+	vim.api.nvim_set_hl(0, "NoGoTestFailed", { link = "DiagnosticError", default = true })
+	vim.api.nvim_set_hl(0, "NoGoErrLifecycle", { link = "LspReferenceText", default = true })
+	vim.api.nvim_set_hl(0, "NoGoErrUnchecked", { link = "DiagnosticUnderlineWarn", default = true })
+	vim.api.nvim_set_hl(0, "NoGoLint", { link = "DiagnosticWarn", default = true })
+	vim.api.nvim_set_hl(0, "NoGoFrequent", { link = "Comment", default = true })
+	vim.api.nvim_set_hl(0, "NoGoDimmed", { link = "Comment", default = true })
+	vim.api.nvim_set_hl(0, "NoGoNoise", { link = "Comment", default = true })
+	vim.api.nvim_set_hl(0, "NoGoSummary", { link = "Comment", default = true })
+	vim.api.nvim_set_hl(0, "NoGoMargin", { link = "NonText", default = true })
+	vim.api.nvim_set_hl(0, "NoGoMarginNormal", { link = "NormalNC", default = true })
+	vim.api.nvim_set_hl(0, "NoGoScrollbar", { link = "Normal", default = true })
+	vim.api.nvim_set_hl(0, "NoGoScrollbarView", { link = "CursorLine", default = true })
+	vim.api.nvim_set_hl(0, "NoGoScrollbarCollapsed", { link = "Comment", default = true })
+	vim.api.nvim_set_hl(0, "NoGoScrollbarRevealed", { link = "NonText", default = true })
+	vim.api.nvim_set_hl(0, "NoGoScrollbarLint", { link = "NoGoLint", default = true })
+
 	-- dont override users highlight group
 	if M.options.highlight_group ~= "NoGoZone" then
 		return
@@ -0,0 +1,225 @@
+local M = {}
+
+-- rows (0-indexed) with a watched sign or stop, per buffer, refreshed by update()
+-- { reveal = { [row] = true }, mark = { [row] = true } }
+M.rows = {}
+
+-- stop locations sent with the NoGoDebugStop User event, bufnr -> row (0-indexed)
+M.stops = {}
+
+-- sign rows seen by the last poll, per buffer, to only refresh when they change
+local last_seen = {}
+
+local timer = nil
+
+-- what the last setup passed, for start() after a stop()
+local state = nil
+
+--- The debugger configuration, when the integration is on
+--- @param config table The plugin configuration
+--- @return table|nil The debugger options
+local function options(config)
+	return config.debugger and config.debugger.enabled and config.debugger or nil
+end
+
+--- Collect the rows (0-indexed) of signs placed in the given groups
+--- @param bufnr number The buffer number
+--- @param groups table List of sign group names
+--- @return table Set of rows
+local function sign_rows(bufnr, groups)
+	local rows = {}
+	for _, group in ipairs(groups or {}) do
+		local ok, placed = pcall(vim.fn.sign_getplaced, bufnr, { group = group })
+		if ok and placed[1] then
+			for _, sign in ipairs(placed[1].signs) do
+				rows[sign.lnum - 1] = true
+			end
+		end
+	end
+	return rows
+end
+
+--- Refresh the sign and stop rows of a buffer, called before the buffer is processed
+--- @param bufnr number The buffer number
+--- @param config table The plugin configuration
+function M.update(bufnr, config)
+	local opts = options(config)
+	if not opts then
+		M.rows[bufnr] = nil
+		return
+	end
+
+	local rows = {
+		reveal = sign_rows(bufnr, opts.reveal_sign_groups),
+		mark = sign_rows(bufnr, opts.mark_sign_groups),
+	}
+
+	if M.stops[bufnr] then
+		rows.reveal[M.stops[bufnr]] = true
+	end
+
+	M.rows[bufnr] = rows
+	last_seen[bufnr] = M.signature(bufnr, opts)
+end
+
+--- What the debugger state asks for a block: reveal it, mark it, or nothing
+--- Only the hidden lines count, a sign on the first line is visible anyway
+--- @param bufnr number The buffer number
+--- @param block table The block (start_row and end_row)
+--- @return string|nil "reveal", "mark" or nil
+function M.block_action(bufnr, block)
+	local rows = M.rows[bufnr]
+	if not rows then
+		return nil
+	end
+
+	local action = nil
+	for row = block.start_row + 1, block.end_row do
+		if rows.reveal[row] then
+			return "reveal"
+		end
+		if rows.mark[row] then
+			action = "mark"
+		end
+	end
+	return action
+end
+
+--- Signature of the watched signs in a buffer, used to detect placement changes
+--- @param bufnr number The buffer number
+--- @param opts table The debugger configuration
+--- @return string The signature
+function M.signature(bufnr, opts)
+	local parts = {}
+	for _, groups in ipairs({ opts.reveal_sign_groups or {}, opts.mark_sign_groups or {} }) do
+		local rows = vim.tbl_keys(sign_rows(bufnr, groups))
+		table.sort(rows)
+		table.insert(parts, table.concat(rows, ","))
+	end
+	return table.concat(parts, "|")
+end
+
+--- Resolve the buffer of a User event payload, either { buf = n } or { file = path }
+--- @param data table|nil The event data
+--- @return number|nil The buffer number, or nil if the file is not loaded
+local function event_buffer(data)
+	if not data then
+		return nil
+	end
+	if data.buf and vim.api.nvim_buf_is_valid(data.buf) then
+		return data.buf
+	end
+	if data.file then
+		local bufnr = vim.fn.bufnr(vim.fn.fnamemodify(data.file, ":p"))
+		if bufnr > 0 then
+			return bufnr
+		end
+	end
+	return nil
+end
+
+--- Stop polling the signs, when no-go is turned off
+function M.stop()
+	if timer then
+		timer:stop()
+		timer:close()
+		timer = nil
+	end
+end
+
+--- Start polling the signs of the visible Go buffers, when it's not already running
+function M.start()
+	if timer or not state then
+		return
+	end
+
+	local opts, refresh = state.opts, state.refresh
+	timer = vim.uv.new_timer()
+	timer:start(
+		opts.debugger.poll_interval,
+		opts.debugger.poll_interval,
+		vim.schedule_wrap(function()
+			M.poll(opts, refresh)
+		end)
+	)
+end
+
+--- Setup the sign watcher and the User event API
+--- signs have no autocmd, so the visible Go buffers are polled for placement changes
+--- @param opts table The plugin configuration
+--- @param refresh function Called with a bufnr when its debugger state changed
+--- @param augroup number The plugin autocmd group
+function M.setup(opts, refresh, augroup)
+	M.stop()
+	state = nil
+
+	if not options(opts) then
+		return
+	end
+	state = { opts = opts, refresh = refresh }
+
+	-- User NoGoDebugStop, data = { file = path, line = lnum } (or buf instead of file)
+	vim.api.nvim_create_autocmd("User", {
+		group = augroup,
+		pattern = "NoGoDebugStop",
+		callback = function(args)
+			local bufnr = event_buffer(args.data)
+			if not bufnr or not args.data.line then
+				return
+			end
+
+			-- only one stop at a time, like a debugger
+			for stopped in pairs(M.stops) do
+				M.stops[stopped] = nil
+				if stopped ~= bufnr and vim.api.nvim_buf_is_valid(stopped) then
+					refresh(stopped)
+				end
+			end
+
+			M.stops[bufnr] = args.data.line - 1
+			refresh(bufnr)
+		end,
+	})
+
+	-- User NoGoDebugContinue, the session moved on or ended
+	vim.api.nvim_create_autocmd("User", {
+		group = augroup,
+		pattern = "NoGoDebugContinue",
+		callback = function()
+			local stopped = vim.tbl_keys(M.stops)
+			M.stops = {}
+			for _, bufnr in ipairs(stopped) do
+				if vim.api.nvim_buf_is_valid(bufnr) then
+					refresh(bufnr)
+				end
+			end
+		end,
+	})
+
+	M.start()
+end
+
+--- Refresh every visible Go buffer whose watched signs changed since the last poll
+--- @param opts table The plugin configuration
+--- @param refresh function Called with a bufnr when its signs changed
+function M.poll(opts, refresh)
+	if not options(opts) then
+		return
+	end
+
+	local visited = {}
+	for _, win in ipairs(vim.api.nvim_list_wins()) do
+		local bufnr = vim.api.nvim_win_get_buf(win)
+		if not visited[bufnr] and vim.bo[bufnr].filetype == "go" then
+			visited[bufnr] = true
+
+			local sig = M.signature(bufnr, opts.debugger)
+			if last_seen[bufnr] ~= sig then
+				last_seen[bufnr] = sig
+				refresh(bufnr)
+			end
+		end
+	end
+end
+
+return M
@@ -0,0 +1,189 @@
+local M = {}
+
+local fold = require("no-go.fold")
+local queries = require("no-go.queries")
+local utils = require("no-go.utils")
+
+-- leaves that are literals, their text is dropped with duplicates.literals
+local literals = {
+	interpreted_string_literal = true,
+	raw_string_literal = true,
+	rune_literal = true,
+	int_literal = true,
+	float_literal = true,
+	imaginary_literal = true,
+}
+
+--- The shape of a subtree: node types and the text of its leaves, literals and identifiers
+--- replaced by placeholders when the configuration ignores them
+--- @param node TSNode The node
+--- @param source number|string The buffer number or source string
+--- @param opts table The duplicates options
+--- @param out table The parts, appended to
+local function normalize(node, source, opts, out)
+	local type = node:type()
+	if type == "comment" then
+		return
+	end
+
+	if literals[type] then
+		table.insert(out, opts.literals and "<lit>" or vim.treesitter.get_node_text(node, source))
+		return
+	end
+	if type == "identifier" then
+		table.insert(out, opts.identifiers and "<id>" or vim.treesitter.get_node_text(node, source))
+		return
+	end
+	if node:child_count() == 0 then
+		table.insert(out, vim.treesitter.get_node_text(node, source))
+		return
+	end
+
+	table.insert(out, "(" .. type)
+	for child in node:iter_children() do
+		normalize(child, source, opts, out)
+	end
+	table.insert(out, ")")
+end
+
+--- The key blocks with the same shape share
+--- @param body TSNode The block node of the body
+--- @param source number|string The buffer number or source string
+--- @param opts table The duplicates options
+--- @return string|nil The key, nil for bodies too small to be worth a helper
+function M.key(body, source, opts)
+	if #utils.block_statements(body) < opts.min_statements then
+		return nil
+	end
+	local out = {}
+	normalize(body, source, opts, out)
+	return table.concat(out, " ")
+end
+
+--- The first statement of a body on one line, for the quickfix text
+--- @param body TSNode The block node of the body
+--- @param source number|string The buffer number or source string
+--- @return string The text
+local function first_statement(body, source)
+	local statement = utils.block_statements(body)[1]
+	return statement and (vim.treesitter.get_node_text(statement, source):gsub("%s+", " ")) or ""
+end
+
+--- Collect the blocks of a buffer from its block index, imports left out
+--- @param bufnr number The buffer number
+--- @param opts table The duplicates options
+--- @param groups table key -> list of sites, added to
+function M.collect_buffer(bufnr, opts, groups)
+	for _, block in ipairs(fold.blocks[bufnr] or {}) do
+		local body = block.kind ~= "import" and fold.body_node(block)
+		local key = body and M.key(body, bufnr, opts)
+		if key then
+			groups[key] = groups[key] or {}
+			table.insert(groups[key], {
+				bufnr = bufnr,
+				lnum = block.start_row + 1,
+				lines = block.end_row - block.start_row + 1,
+				text = first_statement(body, bufnr),
+			})
+		end
+	end
+end
+
+--- Collect the error blocks of every Go file of a module, found like the error matcher finds them
+--- @param root string The module root
+--- @param config table The plugin configuration
+--- @param groups table key -> list of sites, added to
+function M.collect_module(root, config, groups)
+	local query = vim.treesitter.query.parse("go", queries.error_query)
+
+	for _, path in ipairs(utils.module_files(root)) do
+		local tree_root, source = utils.parse_file(path)
+		if tree_root then
+			for _, match in query:iter_matches(tree_root, source, 0, -1, { all = true }) do
+				local captures = {}
+				for id, nodes in pairs(match) do
+					captures[query.captures[id]] = nodes[1]
+				end
+
+				local body = captures.collapse_block
+				if
+					body
+					and utils.is_configured_identifier(captures.err_identifier, source, config)
+					and utils.find_terminator(body, source, config.terminators)
+				then
+					local key = M.key(body, source, config.duplicates)
+					if key then
+						local start_row, _, end_row = captures.if_statement:range()
+						groups[key] = groups[key] or {}
+						table.insert(groups[key], {
+							filename = path,
+							lnum = start_row + 1,
+							lines = end_row - start_row + 1,
+							text = first_statement(body, source),
+						})
+					end
+				end
+			end
+		end
+	end
+end
+
+--- Rank the groups of two or more blocks by the lines they take together
+--- @param groups table key -> list of sites
+--- @return table List of { sites, lines }, most lines first
+function M.rank(groups)
+	local ranked = {}
+	for _, sites in pairs(groups) do
+		if #sites > 1 then
+			local lines = 0
+			for _, site in ipairs(sites) do
+				lines = lines + site.lines
+			end
+			table.insert(ranked, { sites = sites, lines = lines })
+		end
+	end
+	table.sort(ranked, function(a, b)
+		if a.lines ~= b.lines then
+			return a.lines > b.lines
+		end
+		return #a.sites > #b.sites
+	end)
+	return ranked
+end
+
+--- Put the groups of duplicated blocks in the quickfix list, the biggest first
+--- @param bufnr number The buffer number
+--- @param project boolean|nil Look at every Go file of the module, not only the buffer
+--- @param config table The plugin configuration
+function M.run(bufnr, project, config)
+	local groups = {}
+	if project then
+		M.collect_module(utils.module_root(bufnr), config, groups)
+	else
+		M.collect_buffer(bufnr, config.duplicates, groups)
+	end
+
+	local ranked = M.rank(groups)
+	if #ranked == 0 then
+		vim.notify("no-go.nvim: No duplicated blocks", vim.log.levels.INFO)
+		return
+	end
+
+	local items = {}
+	for rank, group in ipairs(ranked) do
+		for _, site in ipairs(group.sites) do
+			table.insert(items, {
+				bufnr = site.bufnr,
+				filename = site.filename,
+				lnum = site.lnum,
+				text = string.format("#%d (%d blocks, %d lines) %s", rank, #group.sites, group.lines, site.text),
+			})
+		end
+	end
+
+	local title = string.format("NoGoDuplicates: %d groups", #ranked)
+	vim.fn.setqflist({}, " ", { title = title, items = items })
+	vim.cmd("copen")
+end
+
+return M
@@ -1,165 +1,511 @@
 local M = {}
 local utils = require("no-go.utils")
 local queries = require("no-go.queries")
+local matchers = require("no-go.matchers")
+local debugger = require("no-go.debugger")
+local lint = require("no-go.lint")
+local margin = require("no-go.margin")
+local scrollbar = require("no-go.scrollbar")
+local testresults = require("no-go.testresults")
+local usage = require("no-go.usage")
 
 M.namespace = vim.api.nvim_create_namespace("no-go")
 
---- Parse and return the Treesitter query for Go error handling patterns
---- @return vim.treesitter.Query|nil query The parsed query or nil if parsing fails
-function M.get_error_query()
-	local has_parser = pcall(vim.treesitter.language.inspect, "go")
+-- anchors for blocks revealed by hand, extmarks so they follow the block on edits
+M.state_namespace = vim.api.nvim_create_namespace("no-go-state")
 
-	local ok, query = pcall(vim.treesitter.query.parse, "go", queries.error_query)
-	if not ok then
-		if not has_parser then
-			vim.notify("no-go.nvim: Go parser not found. Install it with :TSInstall go", vim.log.levels.ERROR)
-		else
-			vim.notify(
-				"no-go.nvim: Failed to parse error query. Try updating the parser with :TSUpdate go",
-				vim.log.levels.ERROR
-			)
-		end
+-- the disclosure level each anchor was set to, bufnr -> { [extmark id] = level }, "expanded" when missing
+M.levels = {}
+
+-- the disclosure levels, in :NoGoCycle order
+M.disclosure = { "hidden", "summary", "dimmed", "expanded" }
+
+-- blocks found by the last process_buffer run, per buffer
+M.blocks = {}
+
+-- changedtick of each buffer when its blocks were found, their nodes are stale after an edit
+M.ticks = {}
+
+--- Clear all extmarks in the specified buffer
+--- @param bufnr number The buffer number
+function M.clear_extmarks(bufnr)
+	vim.api.nvim_buf_clear_namespace(bufnr, M.namespace, 0, -1)
+	M.blocks[bufnr] = nil
+end
+
+--- The disclosure level the block starting at row was set to by hand
+--- @param bufnr number The buffer number
+--- @param row number The start row of the block (0-indexed)
+--- @return string|nil The level, nil when the block follows its category's default
+function M.level(bufnr, row)
+	local marks = vim.api.nvim_buf_get_extmarks(bufnr, M.state_namespace, { row, 0 }, { row, -1 }, { limit = 1 })
+	if #marks == 0 then
 		return nil
 	end
-	return query
+	return (M.levels[bufnr] or {})[marks[1][1]] or "expanded"
 end
 
---- Parse and return the Treesitter query for Go import blocks
---- @return vim.treesitter.Query|nil query The parsed query or nil if parsing fails
-function M.get_import_query()
-	local has_parser = pcall(vim.treesitter.language.inspect, "go")
+--- Set the disclosure level of the block starting at row, nil goes back to the default
+--- @param bufnr number The buffer number
+--- @param row number The start row of the block (0-indexed)
+--- @param level string|nil The level
+function M.set_level(bufnr, row, level)
+	M.levels[bufnr] = M.levels[bufnr] or {}
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, M.state_namespace, { row, 0 }, { row, -1 }, {})) do
+		vim.api.nvim_buf_del_extmark(bufnr, M.state_namespace, mark[1])
+		M.levels[bufnr][mark[1]] = nil
+	end
+	if level then
+		local id = vim.api.nvim_buf_set_extmark(bufnr, M.state_namespace, row, 0, {})
+		M.levels[bufnr][id] = level
+	end
+end
 
-	local ok, query = pcall(vim.treesitter.query.parse, "go", queries.import_query)
-	if not ok then
-		if not has_parser then
-			vim.notify("no-go.nvim: Go parser not found. Install it with :TSInstall go", vim.log.levels.ERROR)
-		else
-			vim.notify(
-				"no-go.nvim: Failed to parse import query. Try updating the parser with :TSUpdate go",
-				vim.log.levels.ERROR
-			)
+--- The disclosure level of a block's category, when nothing was set by hand
+--- @param block table The block
+--- @param config table The plugin configuration
+--- @return string The level
+function M.default_level(block, config)
+	local level = config.disclosure.default[block.category or block.kind]
+	return vim.tbl_contains(M.disclosure, level) and level or "hidden"
+end
+
+--- Check if the block starting at row was revealed by hand
+--- @param bufnr number The buffer number
+--- @param row number The start row of the block (0-indexed)
+--- @return boolean True if the block should stay revealed
+function M.is_revealed(bufnr, row)
+	return M.level(bufnr, row) == "expanded"
+end
+
+--- Find the innermost block containing the row
+--- @param bufnr number The buffer number
+--- @param row number The row number to check (0-indexed)
+--- @return table|nil The block, or nil if the row is not inside a block
+function M.get_block(bufnr, row)
+	local found = nil
+	for _, block in ipairs(M.blocks[bufnr] or {}) do
+		if row >= block.start_row and row <= block.end_row then
+			if not found or block.end_row - block.start_row < found.end_row - found.start_row then
+				found = block
+			end
 		end
-		return nil
 	end
-	return query
+	return found
 end
 
---- Clear all extmarks in the specified buffer
+--- Reveal a collapsed block, or collapse it again if it was revealed by hand
 --- @param bufnr number The buffer number
-function M.clear_extmarks(bufnr)
-	vim.api.nvim_buf_clear_namespace(bufnr, M.namespace, 0, -1)
+--- @param block table The block to toggle
+--- @param config table The plugin configuration
+function M.toggle_block(bufnr, block, config)
+	if M.level(bufnr, block.start_row) then
+		M.set_level(bufnr, block.start_row, nil)
+	else
+		M.set_level(bufnr, block.start_row, "expanded")
+	end
+
+	M.process_buffer(bufnr, config)
 end
 
---- Apply virtual text and concealment to collapse an error handling block
+--- Step a block to its next disclosure level: hidden, summary, dimmed, expanded and around
 --- @param bufnr number The buffer number
---- @param if_node TSNode The if statement node
---- @param _ TSNode The block node to collapse
---- @param return_content string|nil The identifier from the return statement (e.g., "err"), or nil
+--- @param block table The block
 --- @param config table The plugin configuration
-function M.apply_collapse(bufnr, if_node, _, return_content, config)
-	local if_start_row, _, if_end_row, _ = if_node:range()
+--- @return string The new level
+function M.cycle_block(bufnr, block, config)
+	local default = M.default_level(block, config)
+	local current = M.level(bufnr, block.start_row) or default
+	local index = 1
+	for i, level in ipairs(M.disclosure) do
+		if level == current then
+			index = i
+		end
+	end
+	local next_level = M.disclosure[index % #M.disclosure + 1]
+
+	-- kept even when it's the default, a block stepped to hidden stays hidden under the cursor
+	M.set_level(bufnr, block.start_row, next_level)
+	M.process_buffer(bufnr, config)
+	return next_level
+end
+
+--- Drop reveal anchors that no longer sit on the first line of a block
+--- (the block was deleted or edited into something we don't match)
+--- @param bufnr number The buffer number
+local function prune_state(bufnr)
+	local starts = {}
+	for _, block in ipairs(M.blocks[bufnr] or {}) do
+		starts[block.start_row] = true
+	end
 
-	-- check if cursor is inside this block and reveal_on_cursor is enabled
-	if config.reveal_on_cursor then
-		-- get all windows showing this buffer
-		local wins = vim.fn.win_findbuf(bufnr)
-		for _, win in ipairs(wins) do
-			local cursor = vim.api.nvim_win_get_cursor(win)
-			local cursor_row = cursor[1] - 1 -- Convert to 0-indexed
-
-			-- if cursor is on the if line OR inside the block, don't apply concealment!
-			-- this allows the user to navigate inside the revealed error handling code
-			if cursor_row >= if_start_row and cursor_row <= if_end_row then
-				return
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, M.state_namespace, 0, -1, {})) do
+		if not starts[mark[2]] then
+			vim.api.nvim_buf_del_extmark(bufnr, M.state_namespace, mark[1])
+			if M.levels[bufnr] then
+				M.levels[bufnr][mark[1]] = nil
 			end
 		end
 	end
+end
 
-	local brace_start_col = utils.find_opening_pair(bufnr, if_start_row, "{")
-	if not brace_start_col then
-		return
+--- Check if the cursor of any window showing the buffer is inside the rows
+--- @param bufnr number The buffer number
+--- @param start_row number First row of the range (0-indexed)
+--- @param end_row number Last row of the range (0-indexed)
+--- @return boolean True if a cursor is inside the range
+local function cursor_in_range(bufnr, start_row, end_row)
+	-- get all windows showing this buffer
+	local wins = vim.fn.win_findbuf(bufnr)
+	for _, win in ipairs(wins) do
+		local cursor = vim.api.nvim_win_get_cursor(win)
+		local cursor_row = cursor[1] - 1 -- Convert to 0-indexed
+
+		if cursor_row >= start_row and cursor_row <= end_row then
+			return true
+		end
 	end
+	return false
+end
 
-	local brace_end_col = utils.find_closing_pair(bufnr, if_end_row, "}")
-	if not brace_end_col then
+--- Dim the lines a block would hide, when it is left open (mode "dim", adaptive collapsing)
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @param hl_group string The highlight group
+local function dim(bufnr, block, hl_group)
+	if block.end_row > block.start_row then
+		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row + 1, 0, {
+			end_row = block.end_row + 1,
+			end_col = 0,
+			hl_group = hl_group,
+			hl_eol = true,
+			strict = false,
+		})
+	end
+end
+
+--- The block node of a block's body: the one the matcher captured, or the consequence of its if statement
+--- @param block table The block
+--- @return TSNode|nil The body
+function M.body_node(block)
+	if block.body then
+		return block.body
+	end
+	if block.node and block.node:type() == "if_statement" then
+		return block.node:field("consequence")[1]
+	end
+	return nil
+end
+
+--- Dim the logging and other noise statements of an open block (disclosure level "dimmed")
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @param config table The plugin configuration
+local function dim_noise(bufnr, block, config)
+	local body = M.body_node(block)
+	if not body then
 		return
 	end
 
-	-- Conceal from { to end of the if line (hide the opening brace and anything after it)
-	local if_line = vim.api.nvim_buf_get_lines(bufnr, if_start_row, if_start_row + 1, false)[1]
-	if if_line then
-		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, if_start_row, brace_start_col, {
-			end_row = if_start_row,
-			end_col = #if_line, -- End of line
+	for _, statement in ipairs(utils.block_statements(body)) do
+		local name = statement:type() == "expression_statement" and utils.statement_terminator(statement, bufnr)
+		if name and utils.is_terminator(name, config.disclosure.noise) then
+			local start_row, start_col, end_row, end_col = statement:range()
+			vim.api.nvim_buf_set_extmark(bufnr, M.namespace, start_row, start_col, {
+				end_row = end_row,
+				end_col = end_col,
+				hl_group = "NoGoNoise",
+				strict = false,
+			})
+		end
+	end
+end
+
+--- The one line summary of a block's body (disclosure level "summary"): its statements on one line
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @param config table The plugin configuration
+--- @return table|nil The virtual line, indented like the body
+local function summary_line(bufnr, block, config)
+	local body = M.body_node(block)
+	if not body then
+		return nil
+	end
+
+	local parts = {}
+	for _, statement in ipairs(utils.block_statements(body)) do
+		local text = vim.treesitter.get_node_text(statement, bufnr):gsub("%s+", " ")
+		table.insert(parts, text)
+	end
+	if #parts == 0 then
+		return nil
+	end
+
+	local text = table.concat(parts, "; ")
+	if vim.fn.strchars(text) > config.disclosure.summary_width then
+		text = vim.fn.strcharpart(text, 0, config.disclosure.summary_width - 1) .. "…"
+	end
+
+	local line = vim.api.nvim_buf_get_lines(bufnr, block.start_row + 1, block.start_row + 2, false)[1] or ""
+	local indent = string.rep(" ", vim.fn.strdisplaywidth(line:match("^%s*")))
+	return { { indent .. config.disclosure.summary_prefix .. text, "NoGoSummary" } }
+end
+
+--- Conceal a located block and put its marker on the first line
+--- The block stays revealed when it was opened by hand, the mode shows blocks, is revealed often (with adaptive),
+--- holds the cursor (with reveal_on_cursor), holds a debugger stop or was hit by a failing test
+--- Its disclosure level, set with :NoGoCycle or the category's default, can leave it open or dim its noise,
+--- or add a summary line under the marker
+--- @param bufnr number The buffer number
+--- @param block table The block (start_row, end_row, col of the opening pair and marker text)
+--- @param config table The plugin configuration
+--- @return table block The same block, with collapsed and mark_id set when concealed,
+--- and revealed_by saying why it stays open
+function M.collapse(bufnr, block, config)
+	block.collapsed = false
+
+	-- failing tests are listed at the end of the first line, and the block stays revealed
+	if block.tests then
+		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row, 0, {
+			virt_text = { { config.test_results.prefix .. table.concat(block.tests, ", "), "NoGoTestFailed" } },
+			virt_text_pos = "eol",
+		})
+		block.revealed_by = "tests"
+		return block
+	end
+
+	-- blocks revealed by hand (or stepped to a level with :NoGoCycle) stay that way until toggled again
+	local level = M.level(bufnr, block.start_row)
+	block.level = level
+	if level == "expanded" or level == "dimmed" then
+		if level == "dimmed" then
+			dim_noise(bufnr, block, config)
+		end
+		block.revealed_by = "hand"
+		return block
+	end
+
+	-- a level set by hand wins over the mode, adaptive collapsing and the cursor
+	if not level then
+		-- files (or projects) can ask to see their error handling, as is or dimmed
+		if config.mode == "show" or config.mode == "dim" then
+			if config.mode == "dim" then
+				dim(bufnr, block, "NoGoDimmed")
+			end
+			block.revealed_by = "mode"
+			return block
+		end
+
+		-- blocks revealed often are kept open, dimmed with action "dim"
+		if usage.is_frequent(bufnr, block, config) then
+			if config.adaptive.action == "dim" then
+				dim(bufnr, block, "NoGoFrequent")
+			end
+			block.revealed_by = "adaptive"
+			return block
+		end
+
+		-- if cursor is on the if line OR inside the block, don't apply concealment!
+		-- this allows the user to navigate inside the revealed error handling code
+		if config.reveal_on_cursor and cursor_in_range(bufnr, block.start_row, block.end_row) then
+			block.revealed_by = "cursor"
+			return block
+		end
+	end
+
+	local debug_action = debugger.block_action(bufnr, block)
+	if debug_action == "reveal" then
+		block.revealed_by = "debugger"
+		return block
+	end
+
+	-- the category's level, for blocks nobody stepped
+	if not level then
+		level = M.default_level(block, config)
+		block.level = level
+		if level == "expanded" or level == "dimmed" then
+			if level == "dimmed" then
+				dim_noise(bufnr, block, config)
+			end
+			block.revealed_by = "level"
+			return block
+		end
+	end
+
+	-- Conceal from the opening pair to end of the first line (hide the brace and anything after it)
+	local first_line = vim.api.nvim_buf_get_lines(bufnr, block.start_row, block.start_row + 1, false)[1]
+	if first_line then
+		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row, block.col, {
+			end_row = block.start_row,
+			end_col = #first_line, -- End of line
 			conceal = "",
 		})
 	end
 
 	-- hide all intermediate lines completely using conceal_lines, lines between braces
-	-- includes the body of the if block AND the closing brace line (yes!)
-	if if_end_row > if_start_row then
-		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, if_start_row + 1, 0, {
-			end_row = if_end_row, -- end_row is inclusive, so this hides from if_start_row+1 to if_end_row
+	-- includes the body of the block AND the closing line (yes!)
+	if block.end_row > block.start_row then
+		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row + 1, 0, {
+			end_row = block.end_row, -- end_row is inclusive, so this hides from start_row+1 to end_row
 			end_col = 0,
 			conceal_lines = "",
 		})
 	end
 
-	local virtual_text_string = utils.build_virtual_text(return_content, config)
-	vim.api.nvim_buf_set_extmark(bufnr, M.namespace, if_start_row, brace_start_col, {
-		virt_text = { { virtual_text_string, config.highlight_group } },
-		virt_text_pos = "inline",
-	})
+	-- chunks after the text, kept when the text shrinks in narrow windows
+	block.extra = {}
+	if debug_action == "mark" then
+		table.insert(block.extra, { config.debugger.indicator, "NoGoBreakpoint" })
+	end
+	-- findings of the Go tools on hidden lines would be hidden with them
+	if block.lint then
+		local more = #block.lint > 1 and string.format(" (+%d)", #block.lint - 1) or ""
+		table.insert(block.extra, { config.lint.prefix .. block.lint[1] .. more, "NoGoLint" })
+	end
+
+	-- the summary goes under the if line, in place of the hidden lines
+	local summary = level == "summary" and summary_line(bufnr, block, config)
+	if summary then
+		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row, 0, { virt_lines = { summary } })
+	end
+
+	block.collapsed = true
+	M.render_marker(bufnr, block, config)
+
+	return block
 end
 
---- Apply virtual text and concealment to collapse an import block
+--- Pick the rendering level of a block's marker that fits the windows showing the buffer
+--- virtual text belongs to the buffer, so the narrowest window decides
 --- @param bufnr number The buffer number
---- @param import_node TSNode The import statement node
---- @param collapse_node TSNode The import_spec_list node to collapse
+--- @param block table The block (needs levels and extra)
 --- @param config table The plugin configuration
-function M.apply_import_collapse(bufnr, import_node, collapse_node, config)
-	local import_start_row, _, import_end_row, _ = import_node:range()
+--- @return string The text to show
+local function pick_level(bufnr, block, config)
+	local levels = block.levels or { block.text }
+	if not config.virtual_text.adaptive or #levels == 1 then
+		return levels[1]
+	end
 
-	-- check if cursor is inside this block and reveal_on_cursor is enabled
-	if config.reveal_on_cursor then
-		local wins = vim.fn.win_findbuf(bufnr)
-		for _, win in ipairs(wins) do
-			local cursor = vim.api.nvim_win_get_cursor(win)
-			local cursor_row = cursor[1] - 1
+	local extra_width = 0
+	for _, chunk in ipairs(block.extra or {}) do
+		extra_width = extra_width + vim.fn.strdisplaywidth(chunk[1])
+	end
 
-			if cursor_row >= import_start_row and cursor_row <= import_end_row then
-				return
-			end
+	local space = math.huge
+	for _, win in ipairs(vim.fn.win_findbuf(bufnr)) do
+		space = math.min(space, utils.marker_space(win, bufnr, block))
+	end
+
+	for _, level in ipairs(levels) do
+		if vim.fn.strdisplaywidth(level) + extra_width <= space then
+			return level
 		end
 	end
+	return levels[#levels]
+end
 
-	local paren_start_col = utils.find_opening_pair(bufnr, import_start_row, "(")
-	if not paren_start_col then
+--- Set (or update) the inline marker of a collapsed block
+--- @param bufnr number The buffer number
+--- @param block table The collapsed block
+--- @param config table The plugin configuration
+function M.render_marker(bufnr, block, config)
+	local virt_text
+	if block.matcher and block.matcher.render then
+		virt_text = vim.deepcopy(block.matcher.render(block, { bufnr = bufnr, config = config }))
+	else
+		virt_text = { { pick_level(bufnr, block, config), config.highlight_group } }
+	end
+	vim.list_extend(virt_text, block.extra or {})
+
+	local rendered = ""
+	for _, chunk in ipairs(virt_text) do
+		rendered = rendered .. chunk[1]
+	end
+
+	if block.mark_id and block.rendered == rendered then
 		return
 	end
 
-	local paren_end_col = utils.find_closing_pair(bufnr, import_end_row, ")")
-	if not paren_end_col then
+	block.mark_id = vim.api.nvim_buf_set_extmark(bufnr, M.namespace, block.start_row, block.col, {
+		id = block.mark_id,
+		virt_text = virt_text,
+		virt_text_pos = "inline",
+	})
+	block.rendered = rendered
+end
+
+--- Re-pick the marker levels of a buffer after its windows were resized or scrolled
+--- @param bufnr number The buffer number
+--- @param config table The plugin configuration
+function M.render_markers(bufnr, config)
+	if M.ticks[bufnr] ~= vim.api.nvim_buf_get_changedtick(bufnr) then
 		return
 	end
 
-	-- Conceal from ( to end of the import line
-	local import_line = vim.api.nvim_buf_get_lines(bufnr, import_start_row, import_start_row + 1, false)[1]
-	if import_line then
-		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, import_start_row, paren_start_col, {
-			end_row = import_start_row,
-			end_col = #import_line,
-			conceal = "",
-		})
+	for _, block in ipairs(M.blocks[bufnr] or {}) do
+		if block.collapsed then
+			M.render_marker(bufnr, block, config)
+		end
 	end
+end
 
-	-- hide all intermediate lines
-	if import_end_row > import_start_row then
-		vim.api.nvim_buf_set_extmark(bufnr, M.namespace, import_start_row + 1, 0, {
-			end_row = import_end_row,
-			end_col = 0,
-			conceal_lines = "",
-		})
+--- Locate an error handling block, the first pass of process_buffer
+--- @param bufnr number The buffer number
+--- @param if_node TSNode The if statement node
+--- @param err_node TSNode The tested identifier (the @err_identifier capture)
+--- @param return_content string|nil The identifier from the return statement (e.g., "err"), or nil
+--- @param config table The plugin configuration
+--- @return table|nil block The block, or nil if the braces could not be located
+function M.locate_error_block(bufnr, if_node, err_node, return_content, config)
+	local if_start_row, _, if_end_row, _ = if_node:range()
+
+	local brace_start_col = utils.find_opening_pair(bufnr, if_start_row, "{")
+	if not brace_start_col then
+		return nil
+	end
+
+	local brace_end_col = utils.find_closing_pair(bufnr, if_end_row, "}")
+	if not brace_end_col then
+		return nil
+	end
+
+	local func_node, func_name = utils.enclosing_function(if_node, bufnr)
+	local block = {
+		kind = "error",
+		node = if_node,
+		err_node = err_node,
+		start_row = if_start_row,
+		end_row = if_end_row,
+		col = brace_start_col,
+		text = utils.build_virtual_text(return_content, config),
+		levels = utils.build_virtual_text_levels(return_content, config),
+		func_name = func_name,
+	}
+	if func_node then
+		block.func_start_row, _, block.func_end_row = func_node:range()
+	end
+
+	return block
+end
+
+--- Locate an import block, the first pass of process_buffer
+--- @param bufnr number The buffer number
+--- @param import_node TSNode The import statement node
+--- @param collapse_node TSNode The import_spec_list node to collapse
+--- @param config table The plugin configuration
+--- @return table|nil block The block, or nil if the parens could not be located
+function M.locate_import_block(bufnr, import_node, collapse_node, config)
+	local import_start_row, _, import_end_row, _ = import_node:range()
+
+	local paren_start_col = utils.find_opening_pair(bufnr, import_start_row, "(")
+	if not paren_start_col then
+		return nil
+	end
+
+	local paren_end_col = utils.find_closing_pair(bufnr, import_end_row, ")")
+	if not paren_end_col then
+		return nil
 	end
 
 	-- Count import packages
@@ -170,11 +516,82 @@ function M.apply_import_collapse(bufnr, import_node, collapse_node, config)
 		end
 	end
 
-	local virtual_text_string = config.import_virtual_text.prefix .. import_count .. config.import_virtual_text.suffix
-	vim.api.nvim_buf_set_extmark(bufnr, M.namespace, import_start_row, paren_start_col, {
-		virt_text = { { virtual_text_string, config.highlight_group } },
-		virt_text_pos = "inline",
-	})
+	local text = config.import_virtual_text.prefix .. import_count .. config.import_virtual_text.suffix
+	return {
+		kind = "import",
+		node = import_node,
+		start_row = import_start_row,
+		end_row = import_end_row,
+		col = paren_start_col,
+		text = text,
+		levels = { text, tostring(import_count) },
+	}
+end
+
+--- Locate a lazy initialisation block, the first pass of process_buffer
+--- The whole if statement is replaced by its marker: target ??= value
+--- @param bufnr number The buffer number
+--- @param if_node TSNode The if statement node
+--- @param target TSNode The tested expression
+--- @param value TSNode The value assigned to it
+--- @return table|nil block The block, or nil if the braces could not be located
+function M.locate_lazy_block(bufnr, if_node, target, value)
+	local if_start_row, if_start_col, if_end_row, _ = if_node:range()
+
+	if not utils.find_opening_pair(bufnr, if_start_row, "{") or not utils.find_closing_pair(bufnr, if_end_row, "}") then
+		return nil
+	end
+
+	local target_text = vim.treesitter.get_node_text(target, bufnr)
+	local text = target_text .. " ??= " .. utils.short_expression(value, bufnr)
+	local func_node, func_name = utils.enclosing_function(if_node, bufnr)
+	local block = {
+		kind = "lazy",
+		node = if_node,
+		start_row = if_start_row,
+		end_row = if_end_row,
+		col = if_start_col,
+		text = text,
+		levels = { text, target_text .. " ??= …" },
+		func_name = func_name,
+	}
+	if func_node then
+		block.func_start_row, _, block.func_end_row = func_node:range()
+	end
+
+	return block
+end
+
+--- Locate a test assertion block, the first pass of process_buffer
+--- The whole if statement is replaced by its marker: assert (or assert! for t.Fatal*) and what it checks
+--- @param bufnr number The buffer number
+--- @param if_node TSNode The if statement node
+--- @param condition TSNode The condition reporting the failure
+--- @param call string "error" or "fatal"
+--- @return table|nil block The block, or nil if the braces could not be located
+function M.locate_assert_block(bufnr, if_node, condition, call)
+	local if_start_row, if_start_col, if_end_row, _ = if_node:range()
+
+	if not utils.find_opening_pair(bufnr, if_start_row, "{") or not utils.find_closing_pair(bufnr, if_end_row, "}") then
+		return nil
+	end
+
+	local keyword = call == "fatal" and "assert!" or "assert"
+	local func_node, func_name = utils.enclosing_function(if_node, bufnr)
+	local block = {
+		kind = "assert",
+		node = if_node,
+		start_row = if_start_row,
+		end_row = if_end_row,
+		col = if_start_col,
+		text = keyword .. " " .. utils.negate_condition(condition, bufnr),
+		func_name = func_name,
+	}
+	if func_node then
+		block.func_start_row, _, block.func_end_row = func_node:range()
+	end
+
+	return block
 end
 
 --- Process buffer and apply collapses to error handling blocks
@@ -189,15 +606,15 @@ function M.process_buffer(bufnr, config)
 		return
 	end
 
-	-- Skip if buffer is displayed in a codediff diff window
-	local wins = vim.fn.win_findbuf(bufnr)
-	for _, win in ipairs(wins) do
-		if vim.w[win].codediff_restore then
-			return
-		end
+	-- Skip if buffer is displayed in a codediff diff window, or the skip predicate says so
+	if utils.skip_reason(bufnr, config) then
+		return
 	end
 
+	local wins = vim.fn.win_findbuf(bufnr)
+
 	M.clear_extmarks(bufnr)
+	debugger.update(bufnr, config)
 
 	-- set conceallevel at the window level so concealing works
 	for _, win in ipairs(wins) do
@@ -205,11 +622,6 @@ function M.process_buffer(bufnr, config)
 		vim.api.nvim_win_set_option(win, "concealcursor", "nvic")
 	end
 
-	local error_query = M.get_error_query()
-	if not error_query then
-		return
-	end
-
 	local ok, parser = pcall(vim.treesitter.get_parser, bufnr, "go")
 	if not ok then
 		return
@@ -220,74 +632,110 @@ function M.process_buffer(bufnr, config)
 		return
 	end
 
-	local root = tree:root()
-
-	-- iterate err query matches
-	for id, node, _ in error_query:iter_captures(root, bufnr, 0, -1) do
-		local capture_name = error_query.captures[id]
-
-		if capture_name == "if_statement" then -- checking capture group
-			local err_identifier_node = nil
-			local collapse_block_node = nil
-			local return_identifier_node = nil
+	local blocks = matchers.collect(bufnr, tree:root(), config)
 
-			-- gets the nodes we need to make the virtual text, and what we will collapse
-			for child_id, child_node, _ in error_query:iter_captures(node, bufnr, 0, -1) do
-				local child_capture_name = error_query.captures[child_id]
+	table.sort(blocks, function(a, b)
+		return a.start_row < b.start_row
+	end)
 
-				if child_capture_name == "err_identifier" then
-					err_identifier_node = child_node
-				elseif child_capture_name == "collapse_block" then
-					collapse_block_node = child_node
-				elseif child_capture_name == "return_identifier" then
-					return_identifier_node = child_node
-				end
-			end
-
-			-- collapse if:
-			---- identifier is in the configured identifiers list
-			---- have a collapse block (statement_list with return)
-			if
-				err_identifier_node
-				and utils.is_configured_identifier(err_identifier_node, bufnr, config)
-				and collapse_block_node
-			then
-				-- get the returned var name, for err ^ text
-				local return_content = nil
-				if return_identifier_node then
-					return_content = vim.treesitter.get_node_text(return_identifier_node, bufnr)
-				end
-
-				M.apply_collapse(bufnr, node, collapse_block_node, return_content, config)
-			end
-		end
+	-- second pass, now that every block is known
+	testresults.update(bufnr, blocks)
+	lint.update(bufnr, blocks, config)
+	usage.anchor(blocks)
+	for _, block in ipairs(blocks) do
+		M.collapse(bufnr, block, config)
 	end
+	usage.update(bufnr, blocks, config)
 
-	-- iterate import query matches, if enabled
-	if config.fold_imports then
-		local import_query = M.get_import_query()
-		if import_query then
-			for id, node, _ in import_query:iter_captures(root, bufnr, 0, -1) do
-				local capture_name = import_query.captures[id]
-
-				if capture_name == "import_statement" then
-					local collapse_block_node = nil
-
-					for child_id, child_node, _ in import_query:iter_captures(node, bufnr, 0, -1) do
-						local child_capture_name = import_query.captures[child_id]
+	M.blocks[bufnr] = blocks
+	M.ticks[bufnr] = vim.api.nvim_buf_get_changedtick(bufnr)
+	prune_state(bufnr)
+	scrollbar.update(bufnr, blocks, config)
+	margin.update(bufnr, blocks)
+end
 
-						if child_capture_name == "collapse_block" then
-							collapse_block_node = child_node
-						end
-					end
+-- the built-in matchers, registered first so they win over third-party ones on the same row
+
+matchers.register({
+	name = "error",
+	query = queries.error_query,
+	-- collapse if:
+	---- identifier is in the configured identifiers list
+	---- have a collapse block that terminates (return, or one of the configured terminators)
+	filter = function(match, ctx)
+		return match.err_identifier ~= nil
+			and match.collapse_block ~= nil
+			and utils.is_configured_identifier(match.err_identifier, ctx.bufnr, ctx.config)
+	end,
+	describe = function(match, ctx)
+		-- get the returned var name (or the terminator), for err ^ text
+		local terminator, return_content =
+			utils.find_terminator(match.collapse_block, ctx.bufnr, ctx.config.terminators)
+		if not terminator then
+			return nil
+		end
+		if terminator ~= "return" then
+			return_content = terminator
+		end
 
-					if collapse_block_node then
-						M.apply_import_collapse(bufnr, node, collapse_block_node, config)
-					end
-				end
-			end
+		local block =
+			M.locate_error_block(ctx.bufnr, match.if_statement, match.err_identifier, return_content, ctx.config)
+		if block then
+			block.body = match.collapse_block
+			block.terminator = terminator
 		end
-	end
-end
+		return block
+	end,
+})
+
+matchers.register({
+	name = "import",
+	query = queries.import_query,
+	filter = function(_, ctx)
+		return ctx.config.fold_imports
+	end,
+	describe = function(match, ctx)
+		return M.locate_import_block(ctx.bufnr, match.import_statement, match.collapse_block, ctx.config)
+	end,
+})
+
+matchers.register({
+	name = "lazy",
+	query = queries.lazy_query,
+	-- collapse if the target is compared to its zero value and the block only assigns to it
+	filter = function(match, ctx)
+		return ctx.config.fold_lazy_init
+			and match.target ~= nil
+			and match.zero ~= nil
+			and match.collapse_block ~= nil
+			and utils.is_zero_value(match.zero, ctx.bufnr)
+	end,
+	describe = function(match, ctx)
+		local value = utils.lazy_init_value(match.collapse_block, match.target, ctx.bufnr)
+		if not value then
+			return nil
+		end
+		return M.locate_lazy_block(ctx.bufnr, match.if_statement, match.target, value)
+	end,
+})
+
+matchers.register({
+	name = "assert",
+	query = queries.assert_query,
+	-- collapse if the file is a test and the block only reports the failure with t.Error* or t.Fatal*
+	filter = function(match, ctx)
+		return ctx.config.fold_assertions
+			and match.condition ~= nil
+			and match.collapse_block ~= nil
+			and vim.api.nvim_buf_get_name(ctx.bufnr):match("_test%.go$") ~= nil
+	end,
+	describe = function(match, ctx)
+		local call = utils.assertion_call(match.collapse_block, ctx.bufnr)
+		if not call then
+			return nil
+		end
+		return M.locate_assert_block(ctx.bufnr, match.if_statement, match.condition, call)
+	end,
+})
 
 return M
@@ -1,7 +1,23 @@
 local M = {}
 
+local actions = require("no-go.actions")
 local config = require("no-go.config")
+local debugger = require("no-go.debugger")
+local duplicates = require("no-go.duplicates")
 local fold = require("no-go.fold")
+local learn = require("no-go.learn")
+local lint = require("no-go.lint")
+local margin = require("no-go.margin")
+local lifecycle = require("no-go.lifecycle")
+local matchers = require("no-go.matchers")
+local mouse = require("no-go.mouse")
+local peek = require("no-go.peek")
+local review = require("no-go.review")
+local scrollbar = require("no-go.scrollbar")
+local stats = require("no-go.stats")
+local testresults = require("no-go.testresults")
+local trace = require("no-go.trace")
+local usage = require("no-go.usage")
 local utils = require("no-go.utils")
 
 -- Track plugin initialization
@@ -13,10 +29,11 @@ M.augroup = nil
 -- Global enabled state (controls whether folding happens at all)
 M.is_globally_enabled = true
 
--- these vars are for tracking per buffer enabled and disabled states
-M.disabled_buffers = {}
+-- per buffer override of the global state: "on", "off", or nil to inherit it
+M.buffer_overrides = {}
 
-M.enabled_buffers = {}
+-- buffers disabled because processing them raised an error, bufnr -> error message
+M.errored_buffers = {}
 
 M.keymap_buffers = {}
 
@@ -64,6 +81,86 @@ local function setup_keymaps(bufnr, opts)
   M.keymap_buffers[bufnr] = true
 end
 
+--- Work out whether no-go runs in a buffer, and why
+--- in order: an error while processing, the skip predicate, the buffer override,
+--- the exclude patterns, and finally the global state
+--- @param bufnr number The buffer number
+--- @return boolean enabled True if the buffer should be processed
+--- @return string reason What decided it
+function M.buffer_state(bufnr)
+  if M.errored_buffers[bufnr] then
+    return false, "disabled after an error: " .. M.errored_buffers[bufnr]
+  end
+
+  local opts = config.get(bufnr)
+  local skipped = utils.skip_reason(bufnr, opts)
+  if skipped then
+    return false, "skipped: " .. skipped
+  end
+
+  local override = M.buffer_overrides[bufnr]
+  if override then
+    return override == "on", "buffer override"
+  end
+
+  local pattern = utils.excluded_by(bufnr, opts)
+  if pattern then
+    return false, "path matches exclude pattern " .. pattern
+  end
+
+  return M.is_globally_enabled, "global state"
+end
+
+--- Check if no-go should process a buffer
+--- @param bufnr number The buffer number
+--- @return boolean True if the buffer should be processed
+local function is_buffer_enabled(bufnr)
+  local enabled = M.buffer_state(bufnr)
+  return enabled
+end
+
+--- Remove what no-go draws for a buffer, the blocks and their scrollbars
+--- @param bufnr number The buffer number
+local function clear(bufnr)
+  fold.clear_extmarks(bufnr)
+  scrollbar.close(bufnr)
+  mouse.detach(bufnr)
+end
+
+--- Process a buffer, an error disables no-go for that buffer instead of firing on every event
+--- @param bufnr number The buffer number
+local function process(bufnr)
+  local ok, err = pcall(fold.process_buffer, bufnr, config.get(bufnr))
+  if ok then
+    -- turned on again after being turned off, the mappings come back with the blocks
+    mouse.attach(bufnr)
+    return
+  end
+
+  M.errored_buffers[bufnr] = tostring(err)
+  pcall(clear, bufnr)
+  vim.notify(
+    "no-go.nvim: Disabled for this buffer after an error, see :NoGoStatus. :NoGoBufReset to try again",
+    vim.log.levels.WARN
+  )
+end
+
+--- Process or clear every loaded Go buffer, after the global state or the matchers changed
+local function process_all()
+  for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
+    if vim.api.nvim_buf_is_valid(bufnr) and vim.api.nvim_buf_is_loaded(bufnr) then
+      local ft = vim.api.nvim_get_option_value("filetype", { buf = bufnr })
+      if ft == "go" then
+        if is_buffer_enabled(bufnr) then
+          process(bufnr)
+        else
+          clear(bufnr)
+        end
+      end
+    end
+  end
+end
+
 --- Setup the plugin with user configuration
 --- @param user_config table|nil Optional user configuration to override defaults
 function M.setup(user_config)
@@ -79,69 +176,222 @@ function M.setup(user_config)
     callback = function(args)
       setup_keymaps(args.buf, opts)
 
-      if M.disabled_buffers[args.buf] then
+      if not is_buffer_enabled(args.buf) then
         return
       end
 
-      -- skip if globally disabled AND buffer is not explicitly enabled
-      if not M.is_globally_enabled and not M.enabled_buffers[args.buf] then
+      -- debounce updates slightly to avoid excessive processing
+      vim.defer_fn(function()
+        if vim.api.nvim_buf_is_valid(args.buf) and is_buffer_enabled(args.buf) then
+          process(args.buf)
+        end
+      end, 10)
+    end,
+  })
+
+  -- file directives are read again when the file is written, before the update above runs
+  vim.api.nvim_create_autocmd("BufWritePost", {
+    group = M.augroup,
+    pattern = "*.go",
+    callback = function(args)
+      config.read_directives(args.buf)
+    end,
+  })
+
+  vim.api.nvim_create_autocmd("BufDelete", {
+    group = M.augroup,
+    callback = function(args)
+      config.forget_directives(args.buf)
+      mouse.detach(args.buf)
+    end,
+  })
+
+  -- reveal counts are written a moment after they change, what's left when neovim exits
+  vim.api.nvim_create_autocmd("VimLeavePre", {
+    group = M.augroup,
+    callback = function()
+      usage.flush()
+    end,
+  })
+
+  -- the configuration of each buffer is resolved once, a new project file or directory changes it
+  vim.api.nvim_create_autocmd("BufWritePost", {
+    group = M.augroup,
+    pattern = config.project_file,
+    callback = function()
+      config.invalidate()
+      process_all()
+    end,
+  })
+
+  vim.api.nvim_create_autocmd("DirChanged", {
+    group = M.augroup,
+    callback = function()
+      config.invalidate()
+    end,
+  })
+
+  -- Setup CursorMoved autocmd for reveal_on_cursor feature, always: a directive can turn it on
+  vim.api.nvim_create_autocmd({ "CursorMoved", "CursorMovedI" }, {
+    group = M.augroup,
+    pattern = "*.go",
+    callback = function(args)
+      if not is_buffer_enabled(args.buf) or not config.get(args.buf).reveal_on_cursor then
         return
       end
 
-      -- debounce updates slightly to avoid excessive processing
+      -- debounce cursor movements to avoid excessive processing
       vim.defer_fn(function()
-        if vim.api.nvim_buf_is_valid(args.buf) and not M.disabled_buffers[args.buf] then
-          -- process if globally enabled OR buffer is explicitly enabled
-          if M.is_globally_enabled or M.enabled_buffers[args.buf] then
-            fold.process_buffer(args.buf, opts)
-          end
+        if vim.api.nvim_buf_is_valid(args.buf) and is_buffer_enabled(args.buf) then
+          -- need to save the goal cursor
+          local view = vim.fn.winsaveview()
+          process(args.buf)
+          -- and restore it here
+          local new_view = vim.fn.winsaveview()
+          new_view.curswant = view.curswant
+          vim.fn.winrestview(new_view)
         end
       end, 10)
     end,
   })
 
-  -- Setup CursorMoved autocmd for reveal_on_cursor feature
-  if opts.reveal_on_cursor then
-    vim.api.nvim_create_autocmd({ "CursorMoved", "CursorMovedI" }, {
+  if opts.lifecycle then
+    vim.api.nvim_create_autocmd({ "CursorMoved", "CursorMovedI", "BufLeave" }, {
       group = M.augroup,
       pattern = "*.go",
       callback = function(args)
-        if M.disabled_buffers[args.buf] then
-          return
-        end
+        -- after the debounced processing above, so the block index is current
+        vim.defer_fn(function()
+          if not vim.api.nvim_buf_is_valid(args.buf) then
+            return
+          end
 
-        -- skip if globally disabled AND buffer is not explicitly enabled
-        if not M.is_globally_enabled and not M.enabled_buffers[args.buf] then
-          return
+          local block = nil
+          if
+            args.event ~= "BufLeave"
+            and is_buffer_enabled(args.buf)
+            and args.buf == vim.api.nvim_get_current_buf()
+            and fold.ticks[args.buf] == vim.api.nvim_buf_get_changedtick(args.buf)
+          then
+            block = fold.get_block(args.buf, vim.fn.line(".") - 1)
+            if block and (block.kind ~= "error" or block.start_row ~= vim.fn.line(".") - 1) then
+              block = nil
+            end
+          end
+          lifecycle.update(args.buf, block)
+        end, 20)
+      end,
+    })
+  end
+
+  -- adaptive virtual text follows the window width, always: a directive can turn it on
+  vim.api.nvim_create_autocmd({ "WinResized", "WinScrolled" }, {
+    group = M.augroup,
+    callback = function()
+      local rendered = {}
+      for _, win in ipairs(vim.api.nvim_tabpage_list_wins(0)) do
+        local bufnr = vim.api.nvim_win_get_buf(win)
+        if not rendered[bufnr] and fold.blocks[bufnr] then
+          rendered[bufnr] = true
+          local buf_opts = config.get(bufnr)
+          if buf_opts.virtual_text.adaptive then
+            fold.render_markers(bufnr, buf_opts)
+          end
         end
+      end
+    end,
+  })
 
-        -- debounce cursor movements to avoid excessive processing
-        vim.defer_fn(function()
-          if vim.api.nvim_buf_is_valid(args.buf) and not M.disabled_buffers[args.buf] then
-            if M.is_globally_enabled or M.enabled_buffers[args.buf] then
-              -- need to save the goal cursor
-              local view = vim.fn.winsaveview()
-              fold.process_buffer(args.buf, opts)
-              -- and restore it here
-              local new_view = vim.fn.winsaveview()
-              new_view.curswant = view.curswant
-              vim.fn.winrestview(new_view)
-            end
+  if opts.scrollbar.enabled then
+    vim.api.nvim_create_autocmd({ "WinResized", "WinScrolled", "BufWinEnter" }, {
+      group = M.augroup,
+      callback = function()
+        scrollbar.prune()
+        for _, win in ipairs(vim.api.nvim_tabpage_list_wins(0)) do
+          local bufnr = vim.api.nvim_win_get_buf(win)
+          if fold.blocks[bufnr] then
+            scrollbar.update(bufnr, fold.blocks[bufnr], config.get(bufnr))
           end
-        end, 10)
+        end
+      end,
+    })
+
+    vim.api.nvim_create_autocmd("WinClosed", {
+      group = M.augroup,
+      callback = function(args)
+        scrollbar.close_win(tonumber(args.match))
       end,
     })
   end
 
-  if M.is_globally_enabled then
-    local current_buf = vim.api.nvim_get_current_buf()
-    local ft = vim.api.nvim_get_option_value("filetype", { buf = current_buf })
-    if ft == "go" then
-      setup_keymaps(current_buf, opts)
-      fold.process_buffer(current_buf, opts)
+  -- the margin follows its window: scrolling, resizing, another buffer, closing
+  vim.api.nvim_create_autocmd({ "WinResized", "WinScrolled", "BufWinEnter" }, {
+    group = M.augroup,
+    callback = function()
+      if margin.is_open() and vim.api.nvim_win_is_valid(margin.state.source) then
+        local bufnr = vim.api.nvim_win_get_buf(margin.state.source)
+        margin.update(bufnr, fold.blocks[bufnr])
+      end
+    end,
+  })
+
+  vim.api.nvim_create_autocmd("WinClosed", {
+    group = M.augroup,
+    callback = function(args)
+      if margin.state and (tonumber(args.match) == margin.state.source or tonumber(args.match) == margin.state.win) then
+        -- closing a window from WinClosed is not allowed, do it right after
+        vim.schedule(margin.close)
+      end
+    end,
+  })
+
+  -- code actions come from a language server running inside neovim, attached to every Go buffer
+  if opts.code_actions.enabled then
+    vim.api.nvim_create_autocmd("FileType", {
+      group = M.augroup,
+      pattern = "go",
+      callback = function(args)
+        actions.attach(args.buf, utils.module_root(args.buf))
+      end,
+    })
+    for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
+      if vim.api.nvim_buf_is_loaded(bufnr) and vim.bo[bufnr].filetype == "go" then
+        actions.attach(bufnr, utils.module_root(bufnr))
+      end
     end
   end
 
+  -- mouse mappings are local to the Go buffers no-go runs in
+  mouse.setup(opts)
+  if opts.mouse and opts.mouse.enabled then
+    vim.api.nvim_create_autocmd("FileType", {
+      group = M.augroup,
+      pattern = "go",
+      callback = function(args)
+        if is_buffer_enabled(args.buf) then
+          mouse.attach(args.buf)
+        end
+      end,
+    })
+  end
+
+  debugger.setup(opts, function(bufnr)
+    if vim.api.nvim_buf_is_loaded(bufnr) and is_buffer_enabled(bufnr) then
+      process(bufnr)
+    end
+  end, M.augroup)
+  -- starting off, the signs are polled once :NoGoEnable turns it on
+  if not M.is_globally_enabled then
+    debugger.stop()
+  end
+
+  local current_buf = vim.api.nvim_get_current_buf()
+  local ft = vim.api.nvim_get_option_value("filetype", { buf = current_buf })
+  if ft == "go" and is_buffer_enabled(current_buf) then
+    setup_keymaps(current_buf, opts)
+    process(current_buf)
+  end
+
   M.initialized = true
 end
 
@@ -153,55 +403,407 @@ function M.refresh()
   end
 
   local bufnr = vim.api.nvim_get_current_buf()
-  fold.process_buffer(bufnr, config.options)
+  if is_buffer_enabled(bufnr) then
+    process(bufnr)
+  end
 end
 
--- GLOBAL COMMANDS (affect all buffers)
+--- Reveal the block under the cursor, or collapse it again if it was revealed by hand
+function M.toggle_block()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
 
---- Disable the plugin globally (all Go buffers)
-function M.disable()
+  local bufnr = vim.api.nvim_get_current_buf()
+  local block = fold.get_block(bufnr, vim.fn.line(".") - 1)
+  if not block then
+    vim.notify("no-go.nvim: No block under the cursor", vim.log.levels.INFO)
+    return
+  end
+
+  fold.toggle_block(bufnr, block, config.get(bufnr))
+end
+
+--- Step the block under the cursor to its next disclosure level: hidden, summary, dimmed, expanded
+function M.cycle()
   if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
     return
   end
 
-  -- Set global state to disabled
-  M.is_globally_enabled = false
+  local bufnr = vim.api.nvim_get_current_buf()
+  local block = fold.get_block(bufnr, vim.fn.line(".") - 1)
+  if not block then
+    vim.notify("no-go.nvim: No block under the cursor", vim.log.levels.INFO)
+    return
+  end
+
+  local level = fold.cycle_block(bufnr, block, config.get(bufnr))
+  vim.notify("no-go.nvim: Block " .. level, vim.log.levels.INFO)
+end
 
-  -- Clear explicitly enabled buffers (global disable overrides all)
-  M.enabled_buffers = {}
+--- Show the hidden lines of the block under the cursor in a float
+function M.peek()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
 
-  -- Clear extmarks from all Go buffers
-  for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
-    if vim.api.nvim_buf_is_valid(bufnr) then
-      local ft = vim.api.nvim_get_option_value("filetype", { buf = bufnr })
-      if ft == "go" then
-        fold.clear_extmarks(bufnr)
+  local bufnr = vim.api.nvim_get_current_buf()
+  local block = fold.get_block(bufnr, vim.fn.line(".") - 1)
+  if not block then
+    vim.notify("no-go.nvim: No block under the cursor", vim.log.levels.INFO)
+    return
+  end
+
+  peek.open(bufnr, block, "cursor")
+end
+
+--- Reveal and annotate the blocks hit by failing tests
+--- @param file string|nil A file with `go test -json` output, runs `go test -json ./...` when nil
+--- @param clear boolean|nil Forget the current results instead
+function M.test_results(file, clear)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  local function refresh(buffers)
+    for _, bufnr in ipairs(buffers) do
+      if vim.api.nvim_buf_is_loaded(bufnr) and is_buffer_enabled(bufnr) then
+        process(bufnr)
       end
     end
   end
+
+  if clear then
+    local buffers = vim.tbl_keys(testresults.names)
+    testresults.clear()
+    refresh(buffers)
+    return
+  end
+
+  local root = utils.module_root(vim.api.nvim_get_current_buf())
+
+  local function load(lines, err)
+    if not lines then
+      vim.notify("no-go.nvim: Can't run the tests: " .. err, vim.log.levels.ERROR)
+      return
+    end
+    testresults.load(lines, root, function(count, buffers)
+      refresh(buffers)
+      vim.notify(
+        string.format("no-go.nvim: %d failing test(s) with locations in the module", count),
+        vim.log.levels.INFO
+      )
+    end)
+  end
+
+  if file and file ~= "" then
+    local ok, lines = pcall(vim.fn.readfile, vim.fn.expand(file))
+    if not ok then
+      vim.notify("no-go.nvim: Can't read " .. file, vim.log.levels.ERROR)
+      return
+    end
+    load(lines)
+  else
+    vim.notify("no-go.nvim: Running go test -json ./... in " .. root, vim.log.levels.INFO)
+    testresults.run(root, load)
+  end
 end
 
---- Enable the plugin globally (all Go buffers)
-function M.enable()
+--- Run one of the Go tools in cmd/ over the module and mark its findings
+--- @param source string The source name (e.g. "unchecked", "errstyle")
+--- @param clear boolean|nil Clear the findings instead
+local function run_lint(source, clear)
+  local function refresh(buffers)
+    for _, bufnr in ipairs(buffers) do
+      if vim.api.nvim_buf_is_loaded(bufnr) and is_buffer_enabled(bufnr) then
+        process(bufnr)
+      end
+    end
+  end
+
+  if clear then
+    refresh(lint.clear(source))
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  local opts = config.get(bufnr)
+  local root = utils.module_root(bufnr)
+
+  local binary = lint.tools[source].binary
+  vim.notify("no-go.nvim: Running " .. binary .. " in " .. root, vim.log.levels.INFO)
+  lint.run(source, root, function(findings, err)
+    if not findings then
+      vim.notify("no-go.nvim: " .. binary .. " failed: " .. err, vim.log.levels.ERROR)
+      return
+    end
+    refresh(lint.load(source, findings, opts))
+    vim.notify(string.format("no-go.nvim: %d finding(s) from %s", #findings, binary), vim.log.levels.INFO)
+  end)
+end
+
+--- Mark the calls of the module whose error result is never checked
+--- @param clear boolean|nil Clear the marks instead
+function M.unchecked(clear)
   if not M.initialized then
     vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
     return
   end
 
-  -- Set global state to enabled
-  M.is_globally_enabled = true
+  run_lint("unchecked", clear)
+end
 
-  -- Refresh all visible Go buffers (excluding per-buffer disabled ones)
-  for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
-    if vim.api.nvim_buf_is_valid(bufnr) and vim.api.nvim_buf_is_loaded(bufnr) then
-      local ft = vim.api.nvim_get_option_value("filetype", { buf = bufnr })
-      if ft == "go" and not M.disabled_buffers[bufnr] then
-        fold.process_buffer(bufnr, config.options)
+--- Mark the error strings of the module that break the Go conventions
+--- @param clear boolean|nil Clear the marks instead
+function M.errstyle(clear)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  run_lint("errstyle", clear)
+end
+
+--- Find the error blocks of the module that could produce a logged error message,
+--- ordered into a probable call path in the quickfix list
+--- @param message string The message (e.g. "handle submit: validate email: invalid address")
+function M.trace(message)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  message = vim.trim(message or "")
+  if message == "" then
+    vim.notify("no-go.nvim: Give the error message to trace", vim.log.levels.WARN)
+    return
+  end
+
+  trace.run(vim.api.nvim_get_current_buf(), message)
+end
+
+--- List the groups of error blocks with the same shape in the quickfix list, where a helper would do
+--- @param project boolean|nil Look at every Go file of the module, not only the current buffer
+function M.duplicates(project)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  if not project then
+    if vim.bo[bufnr].filetype ~= "go" then
+      vim.notify("no-go.nvim: Not a Go buffer", vim.log.levels.INFO)
+      return
+    end
+    -- the groups come from the block index, make sure it matches the buffer
+    if is_buffer_enabled(bufnr) and fold.ticks[bufnr] ~= vim.api.nvim_buf_get_changedtick(bufnr) then
+      process(bufnr)
+    end
+  end
+
+  duplicates.run(bufnr, project, config.get(bufnr))
+end
+
+--- Tally the error handling conventions of the module and propose a configuration
+--- @param write boolean|nil Write the proposal to the project file right away
+function M.learn(write)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  learn.run(vim.api.nvim_get_current_buf(), write, process_all)
+end
+
+--- Open the margin beside the current window, or close it
+function M.margin()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  if margin.is_open() then
+    margin.close()
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  if vim.bo[bufnr].filetype ~= "go" then
+    vim.notify("no-go.nvim: Not a Go buffer", vim.log.levels.INFO)
+    return
+  end
+  margin.open(vim.api.nvim_get_current_win(), fold.blocks[bufnr], config.get(bufnr))
+end
+
+--- Review the error blocks one by one, marking each ok, to change (with a note) or skipped
+--- @param words table The command arguments: none to start or resume, then ok, change {note}, skip,
+--- summary, stop or reset
+--- @param package boolean|nil Review (or reset) every Go file of the buffer's directory
+function M.review(words, package)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  local action = words[1]
+  local note = table.concat(vim.list_slice(words, 2), " ")
+
+  if action == "ok" or action == "skip" then
+    review.mark(action)
+  elseif action == "change" then
+    if note ~= "" then
+      review.mark("change", note)
+      return
+    end
+    vim.ui.input({ prompt = "What needs to change: " }, function(input)
+      if input then
+        review.mark("change", input)
       end
+    end)
+  elseif action == "stop" then
+    review.stop()
+  elseif action == "summary" then
+    review.summary(review.path(bufnr, config.get(bufnr)))
+  elseif action == "reset" then
+    local root, path = review.path(bufnr, config.get(bufnr))
+    review.reset(root, path, review.files(bufnr, package))
+    vim.notify("no-go.nvim: Forgot the review of " .. (package and "this package" or "this file"), vim.log.levels.INFO)
+  elseif action then
+    vim.notify("no-go.nvim: Unknown review action " .. action, vim.log.levels.WARN)
+  else
+    if vim.bo[bufnr].filetype ~= "go" then
+      vim.notify("no-go.nvim: Not a Go buffer", vim.log.levels.INFO)
+      return
     end
+    if not package and not is_buffer_enabled(bufnr) then
+      vim.notify("no-go.nvim: no-go is off for this buffer, there are no blocks to review", vim.log.levels.WARN)
+      return
+    end
+
+    -- the walk reads the block index of every file, make sure it matches the buffer
+    review.start(bufnr, package, function(file_bufnr)
+      if not is_buffer_enabled(file_bufnr) then
+        return nil
+      end
+      if fold.ticks[file_bufnr] ~= vim.api.nvim_buf_get_changedtick(file_bufnr) or not fold.blocks[file_bufnr] then
+        process(file_bufnr)
+      end
+      return fold.blocks[file_bufnr]
+    end, config.get(bufnr))
   end
 end
 
+--- Forget how often blocks were revealed, so adaptive collapsing starts over
+--- @param all boolean|nil Forget every file, not only the current buffer's
+function M.reset_learning(all)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  if all then
+    usage.reset()
+    process_all()
+    vim.notify("no-go.nvim: Forgot the reveal counts of every file", vim.log.levels.INFO)
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  usage.reset(bufnr)
+  if is_buffer_enabled(bufnr) then
+    process(bufnr)
+  end
+  vim.notify("no-go.nvim: Forgot the reveal counts of this file", vim.log.levels.INFO)
+end
+
+--- Open the error handling dashboard of the current buffer
+function M.stats()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  local bufnr = vim.api.nvim_get_current_buf()
+  if vim.bo[bufnr].filetype ~= "go" then
+    vim.notify("no-go.nvim: Not a Go buffer", vim.log.levels.INFO)
+    return
+  end
+
+  -- the dashboard reads the block index, make sure it matches the buffer
+  -- a disabled buffer is never processed here, that would collapse it again
+  if is_buffer_enabled(bufnr) then
+    if fold.ticks[bufnr] ~= vim.api.nvim_buf_get_changedtick(bufnr) or not fold.blocks[bufnr] then
+      process(bufnr)
+    end
+  elseif not fold.blocks[bufnr] then
+    vim.notify("no-go.nvim: no-go is off for this buffer, :NoGoBufEnable to see its stats", vim.log.levels.INFO)
+    return
+  end
+
+  stats.open(bufnr)
+end
+
+-- MATCHERS
+
+--- Register a matcher, its blocks are collapsed like the built-in error and import blocks
+--- see no-go.matchers for the fields of a matcher
+--- @param matcher table The matcher
+function M.register_matcher(matcher)
+  matchers.register(matcher)
+  if M.initialized then
+    process_all()
+  end
+end
+
+--- Turn the blocks of a matcher category on or off, in every buffer
+--- @param category string The category name (e.g. "error", "import")
+function M.toggle_category(category)
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  if not vim.tbl_contains(matchers.categories(), category) then
+    vim.notify("no-go.nvim: Unknown category " .. category, vim.log.levels.WARN)
+    return
+  end
+
+  local enabled = matchers.toggle_category(category, config.get(vim.api.nvim_get_current_buf()))
+  process_all()
+  vim.notify("no-go.nvim: " .. category .. " blocks " .. (enabled and "on" or "off"), vim.log.levels.INFO)
+end
+
+-- GLOBAL COMMANDS (affect all buffers)
+
+--- Disable the plugin globally (all Go buffers without an "on" override)
+function M.disable()
+  if not M.initialized then
+    return
+  end
+
+  M.is_globally_enabled = false
+  debugger.stop()
+  process_all()
+end
+
+--- Enable the plugin globally (all Go buffers without an "off" override)
+function M.enable()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
+
+  M.is_globally_enabled = true
+  debugger.start()
+  process_all()
+end
+
 --- Toggle the plugin globally (all Go buffers)
 function M.toggle()
   if not M.initialized then
@@ -219,58 +821,98 @@ end
 
 -- BUFFER-SPECIFIC COMMANDS (affect only current buffer)
 
---- Disable the plugin for current buffer only
+--- Set the override of the current buffer and apply it
+--- @param override string|nil "on", "off", or nil to inherit the global state
+local function set_override(override)
+  local bufnr = vim.api.nvim_get_current_buf()
+
+  M.buffer_overrides[bufnr] = override
+  -- an explicit choice is a good moment to try again after an error
+  M.errored_buffers[bufnr] = nil
+
+  if is_buffer_enabled(bufnr) then
+    process(bufnr)
+  else
+    clear(bufnr)
+  end
+end
+
+--- Disable the plugin for current buffer only, whatever the global state
 function M.disable_buffer()
   if not M.initialized then
     return
   end
 
-  local bufnr = vim.api.nvim_get_current_buf()
-
-  -- Mark buffer as disabled
-  M.disabled_buffers[bufnr] = true
+  set_override("off")
+end
 
-  -- Remove from explicitly enabled buffers
-  M.enabled_buffers[bufnr] = nil
+--- Enable the plugin for current buffer only, whatever the global state
+function M.enable_buffer()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
+  end
 
-  -- Clear extmarks for this buffer
-  fold.clear_extmarks(bufnr)
+  set_override("on")
 end
 
---- Enable the plugin for current buffer only
-function M.enable_buffer()
+--- Toggle the plugin for current buffer only
+function M.toggle_buffer()
   if not M.initialized then
     vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
     return
   end
 
   local bufnr = vim.api.nvim_get_current_buf()
+  if is_buffer_enabled(bufnr) then
+    M.disable_buffer()
+  else
+    M.enable_buffer()
+  end
+end
 
-  -- Remove buffer from disabled list
-  M.disabled_buffers[bufnr] = nil
-
-  -- If globally disabled, add to explicitly enabled buffers
-  if not M.is_globally_enabled then
-    M.enabled_buffers[bufnr] = true
+--- Drop the override of the current buffer, so it follows the global state again
+function M.reset_buffer()
+  if not M.initialized then
+    vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
+    return
   end
 
-  fold.process_buffer(bufnr, config.options)
+  set_override(nil)
 end
 
---- Toggle the plugin for current buffer only
-function M.toggle_buffer()
+--- Report the global state, the buffer override, the effective state and why
+function M.status()
   if not M.initialized then
     vim.notify("no-go.nvim: Plugin not initialized. Call setup() first.", vim.log.levels.WARN)
     return
   end
 
   local bufnr = vim.api.nvim_get_current_buf()
-  -- Check if buffer is currently disabled
-  if M.disabled_buffers[bufnr] then
-    M.enable_buffer()
-  else
-    M.disable_buffer()
+  local name = vim.fn.fnamemodify(vim.api.nvim_buf_get_name(bufnr), ":~:.")
+  local enabled, reason = M.buffer_state(bufnr)
+  if vim.bo[bufnr].filetype ~= "go" then
+    enabled, reason = false, "not a Go buffer"
   end
+
+  local _, project_path = config.read_project(bufnr)
+  local _, directives = config.directives(bufnr)
+  local categories = {}
+  for _, category in ipairs(matchers.categories()) do
+    local on = matchers.category_enabled(category, config.get(bufnr))
+    table.insert(categories, category .. (on and "" or " (off)"))
+  end
+
+  local lines = {
+    "no-go.nvim: " .. (name ~= "" and name or "[No Name]"),
+    "  global:    " .. (M.is_globally_enabled and "on" or "off"),
+    "  override:  " .. (M.buffer_overrides[bufnr] or "inherit"),
+    "  effective: " .. (enabled and "on" or "off") .. " (" .. reason .. ")",
+    "  project:   " .. (project_path and vim.fn.fnamemodify(project_path, ":~:.") or "none"),
+    "  file:      " .. (directives or "no directives"),
+    "  matchers:  " .. table.concat(categories, ", "),
+  }
+  vim.notify(table.concat(lines, "\n"), vim.log.levels.INFO)
 end
 
 return M
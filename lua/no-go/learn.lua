@@ -0,0 +1,353 @@
+local M = {}
+
+local config = require("no-go.config")
+local utils = require("no-go.utils")
+
+-- every nil check, whatever the identifier, so the tally sees the whole module
+local guard_query = [[
+(if_statement
+  condition: (binary_expression
+    left: (identifier) @identifier
+    right: (nil))
+  consequence: (block) @body) @guard
+]]
+
+-- calls that tell which framework a handler responds with, only those with a profile are proposed
+-- (echo, fiber and grpc handlers return their errors, "return" already ends their blocks)
+-- c.Status is left out, gin and fiber both have it
+M.frameworks = {
+	["c.JSON"] = "gin",
+	["c.AbortWithStatusJSON"] = "gin",
+	["c.AbortWithError"] = "gin",
+	["c.String"] = "gin",
+	["c.SendStatus"] = "fiber",
+	["http.Error"] = "net/http",
+	["w.WriteHeader"] = "net/http",
+	["echo.NewHTTPError"] = "echo",
+	["status.Error"] = "grpc",
+	["status.Errorf"] = "grpc",
+}
+
+-- what surely leaves a block, with the terminators of the profiles. other calls ending a block are only
+-- candidates: log.Printf(...) as the last statement logs and carries on
+M.exits = { "return", "continue", "break", "goto", "panic", "os.Exit", "log.Fatal*", "log.Panic*" }
+
+--- Check if a terminator surely leaves its block
+--- @param name string The terminator, from utils.statement_terminator
+--- @return boolean True if it's an exit
+local function is_exit(name)
+	if utils.is_terminator(name, M.exits) then
+		return true
+	end
+	for _, profile in pairs(config.profiles) do
+		if utils.is_terminator(name, profile.terminators) then
+			return true
+		end
+	end
+	return false
+end
+
+-- examples kept per tallied name
+local max_examples = 3
+
+--- Count a name and keep a few example sites
+--- @param tally table name -> { count, examples }
+--- @param name string The name to count
+--- @param site string The example site ("file:line")
+local function count(tally, name, site)
+	local entry = tally[name] or { count = 0, examples = {} }
+	entry.count = entry.count + 1
+	if #entry.examples < max_examples then
+		table.insert(entry.examples, site)
+	end
+	tally[name] = entry
+end
+
+--- Sort a tally by count, most used first
+--- @param tally table name -> { count, examples }
+--- @return table List of { name, count, examples }
+local function sorted(tally)
+	local list = {}
+	for name, entry in pairs(tally) do
+		table.insert(list, { name = name, count = entry.count, examples = entry.examples })
+	end
+	table.sort(list, function(a, b)
+		if a.count ~= b.count then
+			return a.count > b.count
+		end
+		return a.name < b.name
+	end)
+	return list
+end
+
+--- Scan the Go files of a module and tally the identifiers of its nil checks, the terminators
+--- of their blocks (exits, and the other calls ending them as candidates) and the selector calls made in them
+--- @param root string The module root
+--- @return table The scan: { files, guards, identifiers, terminators, candidates, calls, terminated }
+function M.scan(root)
+	local query = vim.treesitter.query.parse("go", guard_query)
+	local scan = {
+		files = 0,
+		guards = 0,
+		identifiers = {},
+		terminators = {},
+		candidates = {},
+		calls = {},
+		terminated = {},
+	}
+
+	for _, path in ipairs(utils.module_files(root)) do
+		local tree_root, source = utils.parse_file(path)
+		if tree_root then
+			scan.files = scan.files + 1
+			local relative = path:sub(#root + 2)
+
+			for _, match in query:iter_matches(tree_root, source, 0, -1, { all = true }) do
+				local captures = {}
+				for id, nodes in pairs(match) do
+					captures[query.captures[id]] = nodes[1]
+				end
+
+				local condition = captures.guard:field("condition")[1]
+				local operator = condition and condition:child(1)
+				if operator and operator:type() == "!=" then
+					local site = relative .. ":" .. (captures.guard:start() + 1)
+					local name = vim.treesitter.get_node_text(captures.identifier, source)
+					scan.guards = scan.guards + 1
+					count(scan.identifiers, name, site)
+
+					local statements = utils.block_statements(captures.body)
+					local terminator = nil
+					for _, statement in ipairs(statements) do
+						if statement:type() == "return_statement" then
+							terminator = "return"
+						end
+					end
+					if not terminator and statements[#statements] then
+						terminator = utils.statement_terminator(statements[#statements], source)
+					end
+					if terminator and is_exit(terminator) then
+						count(scan.terminators, terminator, site)
+						scan.terminated[name] = (scan.terminated[name] or 0) + 1
+					elseif terminator then
+						count(scan.candidates, terminator, site)
+					end
+
+					M.count_calls(captures.body, source, scan.calls, site)
+				end
+			end
+		end
+	end
+
+	return scan
+end
+
+--- Tally the selector calls (c.JSON, http.Error...) made anywhere in a node
+--- @param node TSNode The node to walk
+--- @param source number|string The buffer number or source string
+--- @param tally table name -> { count, examples }
+--- @param site string The example site
+function M.count_calls(node, source, tally, site)
+	for child in node:iter_children() do
+		if child:type() == "call_expression" then
+			local func = child:field("function")[1]
+			if func and func:type() == "selector_expression" then
+				count(tally, vim.treesitter.get_node_text(func, source), site)
+			end
+		end
+		M.count_calls(child, source, tally, site)
+	end
+end
+
+--- Turn a scan into a configuration proposal
+--- identifiers need to be common and mostly guard blocks that terminate,
+--- terminators need to show up in a few blocks and surely leave them (candidates are only reported),
+--- the profile is the most used framework, its terminators aren't repeated
+--- without enough guards to tell, identifiers is left out: an empty list would stop all collapsing
+--- @param scan table The scan, from M.scan()
+--- @return table The proposed options: { identifiers, terminators, profile }, each only when found
+--- @return table The detected frameworks, sorted by use
+function M.propose(scan)
+	local min_count = math.max(2, math.ceil(scan.guards * 0.02))
+
+	local identifiers = {}
+	for _, entry in ipairs(sorted(scan.identifiers)) do
+		local terminated = scan.terminated[entry.name] or 0
+		if entry.count >= min_count and terminated * 2 >= entry.count then
+			table.insert(identifiers, entry.name)
+		end
+	end
+
+	local frameworks = {}
+	local seen = {}
+	for _, entry in ipairs(sorted(scan.calls)) do
+		local framework = M.frameworks[entry.name]
+		if framework and not seen[framework] then
+			seen[framework] = true
+			table.insert(frameworks, framework)
+		end
+	end
+	local profile = nil
+	for _, framework in ipairs(frameworks) do
+		if config.profiles[framework] then
+			profile = framework
+			break
+		end
+	end
+	local profile_terminators = profile and config.profiles[profile].terminators or {}
+
+	local terminators = { "return" }
+	for _, entry in ipairs(sorted(scan.terminators)) do
+		if
+			entry.name ~= "return"
+			and entry.count >= min_count
+			and not utils.is_terminator(entry.name, profile_terminators)
+		then
+			table.insert(terminators, entry.name)
+		end
+	end
+
+	local proposal = { terminators = terminators, profile = profile }
+	if #identifiers > 0 then
+		proposal.identifiers = identifiers
+	end
+	return proposal, frameworks
+end
+
+--- Encode options as indented JSON, keys sorted so the file diffs well
+--- @param value any The value to encode
+--- @param indent string|nil The current indentation
+--- @return string The JSON text
+local function encode(value, indent)
+	indent = indent or ""
+	if type(value) ~= "table" then
+		return vim.json.encode(value)
+	end
+
+	local inner = indent .. "  "
+	local items = {}
+	if vim.islist(value) then
+		if #value == 0 then
+			return "[]"
+		end
+		for _, item in ipairs(value) do
+			table.insert(items, inner .. encode(item, inner))
+		end
+		return "[\n" .. table.concat(items, ",\n") .. "\n" .. indent .. "]"
+	end
+
+	local keys = vim.tbl_keys(value)
+	table.sort(keys)
+	for _, key in ipairs(keys) do
+		table.insert(items, inner .. vim.json.encode(key) .. ": " .. encode(value[key], inner))
+	end
+	return "{\n" .. table.concat(items, ",\n") .. "\n" .. indent .. "}"
+end
+
+--- Write the proposal into the project file at the module root, keeping its other options
+--- @param root string The module root
+--- @param proposal table The proposed options
+--- @return string The path written
+function M.write(root, proposal)
+	local path = root .. "/" .. config.project_file
+	local existing = {}
+	if vim.uv.fs_stat(path) then
+		local ok, decoded = pcall(vim.json.decode, table.concat(vim.fn.readfile(path), "\n"))
+		if ok and type(decoded) == "table" then
+			existing = decoded
+		end
+	end
+
+	-- what the scan couldn't tell stays as it was
+	for _, key in ipairs({ "identifiers", "terminators", "profile" }) do
+		if proposal[key] ~= nil and not (type(proposal[key]) == "table" and #proposal[key] == 0) then
+			existing[key] = proposal[key]
+		end
+	end
+	vim.fn.writefile(vim.split(encode(existing), "\n"), path)
+	-- written from inside neovim, it's trusted like a file you edited and :trust'ed yourself
+	vim.secure.trust({ action = "allow", path = path })
+	config.invalidate()
+	return path
+end
+
+--- Render the scan and proposal as report lines
+--- @param root string The module root
+--- @param scan table The scan
+--- @param proposal table The proposed options
+--- @param frameworks table The detected frameworks
+--- @return table The report lines
+function M.report(root, scan, proposal, frameworks)
+	local lines = {
+		string.format("no-go: %d nil checks in %d files of %s", scan.guards, scan.files, root),
+		"",
+		"Proposed " .. config.project_file .. " (w to write, q to close):",
+	}
+	vim.list_extend(lines, vim.split(encode(proposal), "\n"))
+	if not proposal.identifiers then
+		table.insert(lines, "(too few nil checks to propose identifiers, the configured ones are kept)")
+	end
+
+	local function section(title, tally, describe)
+		table.insert(lines, "")
+		table.insert(lines, title)
+		for _, entry in ipairs(sorted(tally)) do
+			table.insert(
+				lines,
+				string.format("  %-28s %5d  %s", describe(entry.name), entry.count, table.concat(entry.examples, ", "))
+			)
+		end
+	end
+
+	section("Identifiers checked against nil:", scan.identifiers, function(name)
+		return name
+	end)
+	section("Terminators of their blocks:", scan.terminators, function(name)
+		return name
+	end)
+	section("Other calls ending their blocks (candidates, not proposed):", scan.candidates, function(name)
+		return name
+	end)
+	section("Calls made in their blocks:", scan.calls, function(name)
+		return M.frameworks[name] and string.format("%s (%s)", name, M.frameworks[name]) or name
+	end)
+
+	if #frameworks > 0 then
+		table.insert(lines, "")
+		table.insert(lines, "Frameworks: " .. table.concat(frameworks, ", "))
+	end
+
+	return lines
+end
+
+--- Scan the module of a buffer and show the proposal in a scratch split
+--- @param bufnr number The buffer number
+--- @param write boolean Write the proposal right away
+--- @param refresh function Called once the proposal is written, to process the loaded Go buffers again
+function M.run(bufnr, write, refresh)
+	local root = utils.module_root(bufnr)
+	local scan = M.scan(root)
+	local proposal, frameworks = M.propose(scan)
+
+	local function save()
+		vim.notify("no-go.nvim: Wrote " .. M.write(root, proposal), vim.log.levels.INFO)
+		refresh()
+	end
+
+	if write then
+		save()
+	end
+
+	local report = vim.api.nvim_create_buf(false, true)
+	vim.api.nvim_buf_set_lines(report, 0, -1, false, M.report(root, scan, proposal, frameworks))
+	vim.bo[report].modifiable = false
+	vim.bo[report].bufhidden = "wipe"
+
+	vim.cmd("botright split")
+	vim.api.nvim_win_set_buf(0, report)
+
+	vim.keymap.set("n", "w", save, { buffer = report, desc = "no-go: write the proposed configuration" })
+	vim.keymap.set("n", "q", "<cmd>close<cr>", { buffer = report, desc = "no-go: close the report" })
+end
+
+return M
@@ -0,0 +1,258 @@
+local M = {}
+
+local utils = require("no-go.utils")
+
+M.namespace = vim.api.nvim_create_namespace("no-go-lifecycle")
+
+-- buffers with highlights on, so they can be cleared when the cursor leaves the guard
+local highlighted = {}
+
+-- nodes that open a scope: blocks, the implicit blocks of if/for/switch statements (their initializer
+-- declares in it) and of case clauses, and function literals (their parameters)
+local scopes = {
+	block = true,
+	if_statement = true,
+	for_statement = true,
+	expression_switch_statement = true,
+	type_switch_statement = true,
+	expression_case = true,
+	type_case = true,
+	default_case = true,
+	communication_case = true,
+	func_literal = true,
+}
+
+--- Classify a read of the identifier by walking up to the statement it belongs to
+--- @param node TSNode The identifier node
+--- @return string "check" in an if condition, "return" in a return statement, "read" otherwise
+local function classify(node)
+	local current = node
+	while current do
+		local parent = current:parent()
+		if not parent then
+			return "read"
+		end
+
+		local type = parent:type()
+		if type == "return_statement" then
+			return "return"
+		elseif type == "if_statement" then
+			local condition = parent:field("condition")[1]
+			if condition and condition:id() == current:id() then
+				return "check"
+			end
+			return "read"
+		elseif scopes[type] then
+			return "read"
+		end
+		current = parent
+	end
+	return "read"
+end
+
+--- Collect the uses of every binding of a name in a function, in source order
+--- A scope walk: := and var declare a new binding in the innermost scope (if/for/switch
+--- initializers in the statement's own scope), so a shadowing err is another variable
+--- @param func_node TSNode The function to walk
+--- @param name string The identifier to follow
+--- @param bufnr number The buffer number
+--- @return table uses List of { node, kind, binding }, kind is "assign", "check", "return" or "read"
+--- @return table bindings identifier node id -> binding, to find the one a node refers to
+local function collect_uses(func_node, name, bufnr)
+	local uses = {}
+	local bindings = {}
+
+	-- the binding outside the function (a package variable, or the function's own parameter)
+	local root = { vars = {} }
+	root.vars[name] = {}
+
+	local function lookup(scope)
+		while scope do
+			if scope.vars[name] then
+				return scope.vars[name]
+			end
+			scope = scope.parent
+		end
+		return root.vars[name]
+	end
+
+	local function is_name(node)
+		return node:type() == "identifier" and vim.treesitter.get_node_text(node, bufnr) == name
+	end
+
+	local function record(node, kind, binding)
+		bindings[node:id()] = binding
+		table.insert(uses, { node = node, kind = kind, binding = binding })
+	end
+
+	local walk
+
+	--- The identifiers of a declaration's or assignment's left side, the rest is walked as reads
+	--- @param declare boolean Whether it's a := (or a range with :=)
+	local function targets(list, scope, declare)
+		for child in list:iter_children() do
+			if is_name(child) then
+				local binding = lookup(scope)
+				-- := reuses a variable of the same scope, and declares one otherwise
+				if declare and not scope.vars[name] then
+					binding = {}
+					scope.vars[name] = binding
+				end
+				record(child, "assign", binding)
+			elseif child:named() then
+				walk(child, scope)
+			end
+		end
+	end
+
+	walk = function(node, scope)
+		local type = node:type()
+		-- a function literal's body shares the scope of its parameters
+		local parent = node:parent()
+		if scopes[type] and not (type == "block" and parent and parent:type() == "func_literal") then
+			scope = { vars = {}, parent = scope }
+		end
+
+		if type == "short_var_declaration" or type == "assignment_statement" then
+			-- the right side is evaluated before the left side is (re)declared: err := wrap(err)
+			for _, right in ipairs(node:field("right")) do
+				walk(right, scope)
+			end
+			for _, left in ipairs(node:field("left")) do
+				targets(left, scope, type == "short_var_declaration")
+			end
+			return
+		elseif type == "var_spec" then
+			for _, value in ipairs(node:field("value")) do
+				walk(value, scope)
+			end
+			for child in node:iter_children() do
+				if is_name(child) then
+					local binding = {}
+					scope.vars[name] = binding
+					if node:field("value")[1] then
+						record(child, "assign", binding)
+					end
+				end
+			end
+			return
+		elseif type == "range_clause" then
+			for _, right in ipairs(node:field("right")) do
+				walk(right, scope)
+			end
+			local declares = false
+			for child in node:iter_children() do
+				declares = declares or child:type() == ":="
+			end
+			for _, left in ipairs(node:field("left")) do
+				targets(left, scope, declares)
+			end
+			return
+		elseif type == "parameter_declaration" or type == "variadic_parameter_declaration" then
+			for child in node:iter_children() do
+				if is_name(child) then
+					scope.vars[name] = {}
+				end
+			end
+			return
+		elseif type == "type_switch_statement" then
+			local alias = node:field("alias")[1]
+			if alias and is_name(alias) then
+				-- each case has its own copy, but none of them is the guarded err
+				scope.vars[name] = {}
+			end
+		end
+
+		if is_name(node) then
+			record(node, classify(node), lookup(scope))
+			return
+		end
+
+		for child in node:iter_children() do
+			if not (type == "type_switch_statement" and child:type() == "identifier") then
+				walk(child, scope)
+			end
+		end
+	end
+
+	-- the function's parameters and named results are the outermost bindings
+	local body = func_node:field("body")[1]
+	local scope = { vars = {}, parent = root }
+	for _, field in ipairs({ "receiver", "parameters", "result" }) do
+		for _, list in ipairs(func_node:field(field)) do
+			if list:type() == "parameter_list" then
+				walk(list, scope)
+			end
+		end
+	end
+	if body then
+		-- the body's top level is the parameters' scope: a, err := f() reuses a named result err
+		for child in body:iter_children() do
+			walk(child, scope)
+		end
+	end
+	return uses, bindings
+end
+
+--- Clear the lifecycle highlights of a buffer
+--- @param bufnr number The buffer number
+function M.clear(bufnr)
+	if highlighted[bufnr] and vim.api.nvim_buf_is_valid(bufnr) then
+		vim.api.nvim_buf_clear_namespace(bufnr, M.namespace, 0, -1)
+	end
+	highlighted[bufnr] = nil
+end
+
+--- Highlight the assignments, checks and returns of the identifier tested by the guard
+--- under the cursor, in the function around it. An assignment overwritten before anything reads it
+--- is highlighted as a warning.
+--- @param bufnr number The buffer number
+--- @param block table|nil The error block whose first line holds the cursor, or nil to clear
+function M.update(bufnr, block)
+	M.clear(bufnr)
+
+	if not block or not block.err_node then
+		return
+	end
+
+	local func_node = utils.enclosing_function(block.err_node, bufnr)
+	if not func_node then
+		return
+	end
+
+	local name = vim.treesitter.get_node_text(block.err_node, bufnr)
+	local all, bindings = collect_uses(func_node, name, bufnr)
+
+	-- only the variable the guard tests, not the ones shadowing it or shadowed by it
+	local binding = bindings[block.err_node:id()]
+	local uses = vim.tbl_filter(function(use)
+		return use.binding == binding
+	end, all)
+
+	for i, use in ipairs(uses) do
+		local group = "NoGoErrLifecycle"
+
+		if use.kind == "assign" then
+			-- the value is lost if the next thing that happens to it is another assignment,
+			-- any read in between (a check, a return, log.Print(err)...) counts as handling it
+			local next_use = uses[i + 1]
+			if next_use and next_use.kind == "assign" then
+				group = "NoGoErrUnchecked"
+			end
+		end
+
+		-- plain reads only count for the check above
+		if use.kind ~= "read" then
+			local start_row, start_col, end_row, end_col = use.node:range()
+			vim.api.nvim_buf_set_extmark(bufnr, M.namespace, start_row, start_col, {
+				end_row = end_row,
+				end_col = end_col,
+				hl_group = group,
+			})
+		end
+	end
+
+	highlighted[bufnr] = true
+end
+
+return M
@@ -0,0 +1,189 @@
+local M = {}
+
+-- anchors on the lines the Go tools in cmd/ reported, extmarks so they follow edits
+M.namespace = vim.api.nvim_create_namespace("no-go-lint")
+
+-- findings per anchor, bufnr -> { [mark_id] = { source, message } }
+M.marks = {}
+
+-- findings in files that are not loaded yet, source -> path -> list of { row, col, message }
+M.pending = {}
+
+-- the binary of each source, built from cmd/<binary>, and the flags for its JSON output
+M.tools = {
+	unchecked = { binary = "no-go-unchecked", args = { "-json" } },
+	errstyle = { binary = "no-go-errstyle", args = { "-format", "json" } },
+}
+
+-- the plugin directory, cmd/ holds the Go module of the tools
+local plugin_root = vim.fn.fnamemodify(debug.getinfo(1, "S").source:sub(2), ":p:h:h:h")
+
+--- Build the command running a tool: the one configured in setup(), the installed binary,
+--- or `go run` in the plugin's cmd module
+--- only setup() can name the command, never a project file or file directive
+--- @param source string The source name (e.g. "unchecked", "errstyle")
+--- @return table The command
+function M.command(source)
+	local configured = require("no-go.config").options.lint[source]
+	if configured then
+		return vim.deepcopy(configured)
+	end
+
+	local binary = M.tools[source].binary
+	if vim.fn.executable(binary) == 1 then
+		return { binary }
+	end
+	return { "go", "-C", plugin_root .. "/cmd", "run", "./" .. binary }
+end
+
+--- Decode the -json output of a tool
+--- @param output string The output
+--- @return table|nil List of { file, line, col, message, rule }, or nil if it isn't JSON
+function M.parse(output)
+	local ok, findings = pcall(vim.json.decode, output)
+	if not ok or type(findings) ~= "table" then
+		return nil
+	end
+
+	return vim.tbl_filter(function(finding)
+		return type(finding) == "table" and type(finding.file) == "string" and type(finding.line) == "number"
+	end, findings)
+end
+
+--- Put the anchor and end of line marker of a finding
+--- @param bufnr number The buffer number
+--- @param source string The source name
+--- @param finding table { row, col, message }
+--- @param config table The plugin configuration
+local function place(bufnr, source, finding, config)
+	local line = vim.api.nvim_buf_get_lines(bufnr, finding.row, finding.row + 1, false)[1]
+	if not line then
+		return
+	end
+
+	local id = vim.api.nvim_buf_set_extmark(bufnr, M.namespace, finding.row, math.min(finding.col, #line), {
+		virt_text = { { config.lint.prefix .. finding.message, "NoGoLint" } },
+		virt_text_pos = "eol",
+	})
+	M.marks[bufnr] = M.marks[bufnr] or {}
+	M.marks[bufnr][id] = { source = source, message = finding.message }
+end
+
+--- Turn the pending findings of a file into anchors once its buffer is loaded
+--- @param bufnr number The buffer number
+--- @param config table The plugin configuration
+local function place_pending(bufnr, config)
+	local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	for source, files in pairs(M.pending) do
+		for _, finding in ipairs(files[path] or {}) do
+			place(bufnr, source, finding, config)
+		end
+		files[path] = nil
+	end
+end
+
+--- Attach the findings hidden by each block, called before the blocks are collapsed
+--- so the collapsed line can show them
+--- @param bufnr number The buffer number
+--- @param blocks table The located blocks
+--- @param config table The plugin configuration
+function M.update(bufnr, blocks, config)
+	place_pending(bufnr, config)
+
+	local marks = M.marks[bufnr]
+	if not marks then
+		return
+	end
+
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, M.namespace, 0, -1, {})) do
+		local id, row = mark[1], mark[2]
+		for _, block in ipairs(blocks) do
+			if marks[id] and row > block.start_row and row <= block.end_row then
+				block.lint = block.lint or {}
+				table.insert(block.lint, marks[id].message)
+			end
+		end
+	end
+end
+
+--- Forget the findings of a source
+--- @param source string The source name
+--- @return table The loaded buffers that had some
+function M.clear(source)
+	local affected = {}
+	for bufnr, marks in pairs(M.marks) do
+		for id, data in pairs(marks) do
+			if data.source == source then
+				if vim.api.nvim_buf_is_valid(bufnr) then
+					vim.api.nvim_buf_del_extmark(bufnr, M.namespace, id)
+				end
+				marks[id] = nil
+				affected[bufnr] = true
+			end
+		end
+	end
+	M.pending[source] = nil
+	return vim.tbl_keys(affected)
+end
+
+--- Load the findings of a source, replacing its previous ones
+--- @param source string The source name
+--- @param findings table List of { file, line, col, message, rule }, from parse()
+--- @param config table The plugin configuration
+--- @return table The loaded buffers whose findings changed
+function M.load(source, findings, config)
+	local affected = {}
+	for _, bufnr in ipairs(M.clear(source)) do
+		affected[bufnr] = true
+	end
+
+	M.pending[source] = {}
+	for _, finding in ipairs(findings) do
+		local path = vim.fs.normalize(finding.file)
+		M.pending[source][path] = M.pending[source][path] or {}
+		table.insert(M.pending[source][path], {
+			row = finding.line - 1,
+			col = math.max((finding.col or 1) - 1, 0),
+			message = (finding.message or "") .. (finding.rule and " (" .. finding.rule .. ")" or ""),
+		})
+	end
+
+	for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
+		if vim.api.nvim_buf_is_loaded(bufnr) then
+			local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+			if M.pending[source][path] then
+				place_pending(bufnr, config)
+				affected[bufnr] = true
+			end
+		end
+	end
+
+	return vim.tbl_keys(affected)
+end
+
+--- Run a tool over the module and decode its findings
+--- @param source string The source name
+--- @param root string The module root
+--- @param on_done function Called with the findings, or nil and the error output
+function M.run(source, root, on_done)
+	local cmd = M.command(source)
+	vim.list_extend(cmd, M.tools[source].args)
+	table.insert(cmd, root .. "/...")
+
+	local ok, err = pcall(vim.system, cmd, { cwd = root, text = true }, function(result)
+		vim.schedule(function()
+			-- the tools exit with 1 when they report something
+			local findings = (result.code == 0 or result.code == 1) and M.parse(result.stdout or "")
+			if findings then
+				on_done(findings)
+			else
+				on_done(nil, vim.trim((result.stderr or "") .. (result.stdout or "")))
+			end
+		end)
+	end)
+	if not ok then
+		on_done(nil, tostring(err))
+	end
+end
+
+return M
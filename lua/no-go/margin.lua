@@ -0,0 +1,157 @@
+local M = {}
+
+local utils = require("no-go.utils")
+
+M.namespace = vim.api.nvim_create_namespace("no-go-margin")
+
+-- the open margin, if any: { source = win, win = win, buf = bufnr }
+M.state = nil
+
+--- Check if the margin is open
+--- @return boolean True if the margin window is open
+function M.is_open()
+	return M.state ~= nil and vim.api.nvim_win_is_valid(M.state.win)
+end
+
+--- Close the margin
+function M.close()
+	if M.state and vim.api.nvim_win_is_valid(M.state.win) then
+		vim.api.nvim_win_close(M.state.win, true)
+	end
+	M.state = nil
+end
+
+--- The hidden lines of a block, without their common indentation
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @return table The lines
+local function hidden_lines(bufnr, block)
+	local lines = vim.api.nvim_buf_get_lines(bufnr, block.start_row + 1, block.end_row + 1, false)
+
+	local indent = nil
+	for _, line in ipairs(lines) do
+		if line:match("%S") then
+			local len = #line:match("^%s*")
+			indent = indent and math.min(indent, len) or len
+		end
+	end
+	for i, line in ipairs(lines) do
+		lines[i] = line:sub((indent or 0) + 1)
+	end
+	return lines
+end
+
+--- Render the hidden lines of the collapsed blocks on screen, each from the row of its if line
+--- a block whose lines run into the next one is cut short, with the number of lines left out
+--- @param blocks table|nil The blocks of the buffer in the source window
+function M.render(blocks)
+	if not M.is_open() then
+		M.state = nil
+		return
+	end
+	local source, win, buf = M.state.source, M.state.win, M.state.buf
+	local bufnr = vim.api.nvim_win_get_buf(source)
+	local height = vim.api.nvim_win_get_height(source)
+
+	-- the collapsed blocks on screen, by window row
+	local placed = {}
+	for _, block in ipairs(blocks or {}) do
+		if block.collapsed and block.end_row > block.start_row then
+			local row = utils.screen_row(source, block.start_row)
+			if row and row >= 0 and row < height then
+				table.insert(placed, { row = row, block = block })
+			end
+		end
+	end
+	table.sort(placed, function(a, b)
+		return a.row < b.row
+	end)
+
+	local lines = {}
+	for i = 1, height do
+		lines[i] = ""
+	end
+
+	local marks = {}
+	for i, entry in ipairs(placed) do
+		local next_row = placed[i + 1] and placed[i + 1].row or height
+		local available = next_row - entry.row
+		local block_lines = hidden_lines(bufnr, entry.block)
+
+		if #block_lines > available then
+			local left_out = #block_lines - available + 1
+			block_lines = vim.list_slice(block_lines, 1, available - 1)
+			table.insert(block_lines, string.format("… (+%d)", left_out))
+		end
+
+		for j, line in ipairs(block_lines) do
+			lines[entry.row + j] = line
+		end
+		table.insert(marks, { first = entry.row, last = entry.row + #block_lines - 1 })
+	end
+
+	vim.bo[buf].modifiable = true
+	vim.api.nvim_buf_set_lines(buf, 0, -1, false, lines)
+	vim.bo[buf].modifiable = false
+
+	vim.api.nvim_buf_clear_namespace(buf, M.namespace, 0, -1)
+	for _, mark in ipairs(marks) do
+		for row = mark.first, mark.last do
+			vim.api.nvim_buf_set_extmark(buf, M.namespace, row, 0, { sign_text = "│", sign_hl_group = "NoGoMargin" })
+		end
+	end
+
+	-- the margin doesn't scroll on its own, row i is the source window's row i
+	vim.api.nvim_win_call(win, function()
+		vim.fn.winrestview({ topline = 1, lnum = 1, col = 0 })
+	end)
+end
+
+--- Open the margin beside a window
+--- @param source number The window whose blocks to show
+--- @param blocks table|nil The blocks of its buffer
+--- @param config table The plugin configuration
+function M.open(source, blocks, config)
+	M.close()
+
+	local source_buf = vim.api.nvim_win_get_buf(source)
+	local buf = vim.api.nvim_create_buf(false, true)
+	vim.bo[buf].bufhidden = "wipe"
+	vim.bo[buf].tabstop = vim.bo[source_buf].tabstop
+
+	local win = vim.api.nvim_open_win(buf, false, {
+		split = "right",
+		win = source,
+		width = config.margin.width,
+	})
+	vim.wo[win].number = false
+	vim.wo[win].relativenumber = false
+	vim.wo[win].wrap = false
+	vim.wo[win].cursorline = false
+	vim.wo[win].list = false
+	vim.wo[win].foldcolumn = "0"
+	vim.wo[win].signcolumn = "yes:1"
+	vim.wo[win].winfixwidth = true
+	vim.wo[win].winhighlight = "Normal:NoGoMarginNormal"
+
+	-- highlight without setting the filetype, so language servers don't attach to the scratch buffer
+	pcall(vim.treesitter.start, buf, "go")
+
+	M.state = { source = source, win = win, buf = buf }
+	M.render(blocks)
+end
+
+--- Render the margin again if it shows this buffer
+--- @param bufnr number The buffer number
+--- @param blocks table|nil The blocks of the buffer
+function M.update(bufnr, blocks)
+	if M.is_open() and vim.api.nvim_win_is_valid(M.state.source) then
+		if vim.api.nvim_win_get_buf(M.state.source) == bufnr then
+			M.render(blocks)
+		end
+	elseif M.state then
+		M.close()
+	end
+end
+
+return M
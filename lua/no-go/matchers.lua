@@ -0,0 +1,170 @@
+local M = {}
+
+-- registered matchers, in registration order (the built-in error and import matchers first)
+-- the first matcher to describe a block starting on a row wins that row
+M.list = {}
+
+-- categories toggled at runtime, category -> boolean, wins over config.categories
+M.toggled = {}
+
+-- parsed queries, matcher name -> query (false when the query failed to parse)
+local parsed = {}
+
+--- Register a matcher, or replace the matcher registered under the same name
+--- A matcher is a table with:
+---   name: string, unique
+---   query: string, a Go treesitter query
+---   category: string|nil, the category toggled by :NoGoCategoryToggle (defaults to name)
+---   filter: function(match, ctx) -> boolean, optional, false drops the match
+---   describe: function(match, ctx) -> block|nil, the block to collapse:
+---     { start_row, end_row, col = where concealing starts on the first line, text or levels }
+---   render: function(block, ctx) -> chunks, optional, the virtual text of the marker
+--- match maps capture names to their first node, ctx is { bufnr, config }
+--- @param matcher table The matcher
+function M.register(matcher)
+	vim.validate("matcher", matcher, "table")
+	vim.validate("matcher.name", matcher.name, "string")
+	vim.validate("matcher.query", matcher.query, "string")
+	vim.validate("matcher.category", matcher.category, "string", true)
+	vim.validate("matcher.filter", matcher.filter, "function", true)
+	vim.validate("matcher.describe", matcher.describe, "function")
+	vim.validate("matcher.render", matcher.render, "function", true)
+
+	matcher.category = matcher.category or matcher.name
+	parsed[matcher.name] = nil
+
+	for i, existing in ipairs(M.list) do
+		if existing.name == matcher.name then
+			M.list[i] = matcher
+			return
+		end
+	end
+	table.insert(M.list, matcher)
+end
+
+--- Find a registered matcher by name
+--- @param name string The matcher name
+--- @return table|nil The matcher
+function M.get(name)
+	for _, matcher in ipairs(M.list) do
+		if matcher.name == name then
+			return matcher
+		end
+	end
+	return nil
+end
+
+--- Parse (once) and return the query of a matcher
+--- @param matcher table The matcher
+--- @return vim.treesitter.Query|nil query The parsed query or nil if parsing fails
+function M.query(matcher)
+	if parsed[matcher.name] ~= nil then
+		return parsed[matcher.name] or nil
+	end
+
+	local ok, query = pcall(vim.treesitter.query.parse, "go", matcher.query)
+	if not ok then
+		if not pcall(vim.treesitter.language.inspect, "go") then
+			vim.notify("no-go.nvim: Go parser not found. Install it with :TSInstall go", vim.log.levels.ERROR)
+			-- the parser may be installed later, try again next time
+			return nil
+		end
+		vim.notify(
+			"no-go.nvim: Failed to parse " .. matcher.name .. " query. Try updating the parser with :TSUpdate go",
+			vim.log.levels.ERROR
+		)
+		parsed[matcher.name] = false
+		return nil
+	end
+
+	parsed[matcher.name] = query
+	return query
+end
+
+--- List the categories of the registered matchers
+--- @return table The category names, in registration order
+function M.categories()
+	local categories = {}
+	for _, matcher in ipairs(M.list) do
+		if not vim.tbl_contains(categories, matcher.category) then
+			table.insert(categories, matcher.category)
+		end
+	end
+	return categories
+end
+
+--- Check if the blocks of a category are collapsed
+--- @param category string The category name
+--- @param config table The plugin configuration
+--- @return boolean True if the category is on
+function M.category_enabled(category, config)
+	if M.toggled[category] ~= nil then
+		return M.toggled[category]
+	end
+	return (config.categories or {})[category] ~= false
+end
+
+--- Flip a category for every buffer
+--- @param category string The category name
+--- @param config table The plugin configuration, for the current state
+--- @return boolean The new state
+function M.toggle_category(category, config)
+	M.toggled[category] = not M.category_enabled(category, config)
+	return M.toggled[category]
+end
+
+--- Fill in what a matcher's describe may leave out
+--- @param bufnr number The buffer number
+--- @param block table The described block
+--- @param matcher table The matcher that described it
+--- @return table The same block
+local function complete(bufnr, block, matcher)
+	-- without a column the marker goes at the end of the first line
+	if not block.col then
+		local line = vim.api.nvim_buf_get_lines(bufnr, block.start_row, block.start_row + 1, false)[1]
+		block.col = #(line or "")
+	end
+	if not block.text and not block.levels and not matcher.render then
+		block.text = " " .. matcher.name
+	end
+
+	block.kind = block.kind or matcher.name
+	block.category = matcher.category
+	block.matcher = matcher
+	return block
+end
+
+--- Run every enabled matcher over a tree and collect the blocks they describe
+--- @param bufnr number The buffer number
+--- @param root TSNode The root of the buffer's tree
+--- @param config table The plugin configuration
+--- @return table The blocks, in matcher then source order, one per start row
+function M.collect(bufnr, root, config)
+	local ctx = { bufnr = bufnr, config = config }
+	local blocks = {}
+	local seen = {}
+
+	for _, matcher in ipairs(M.list) do
+		local query = M.category_enabled(matcher.category, config) and M.query(matcher)
+		if query then
+			for _, captures in query:iter_matches(root, bufnr, 0, -1, { all = true }) do
+				local match = {}
+				for id, nodes in pairs(captures) do
+					match[query.captures[id]] = nodes[1]
+				end
+
+				if not matcher.filter or matcher.filter(match, ctx) then
+					local block = matcher.describe(match, ctx)
+					if block and not seen[block.start_row] then
+						seen[block.start_row] = true
+						table.insert(blocks, complete(bufnr, block, matcher))
+					end
+				end
+			end
+		end
+	end
+
+	return blocks
+end
+
+return M
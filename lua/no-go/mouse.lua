@@ -0,0 +1,123 @@
+local M = {}
+
+local config = require("no-go.config")
+local fold = require("no-go.fold")
+local peek = require("no-go.peek")
+local scrollbar = require("no-go.scrollbar")
+local utils = require("no-go.utils")
+
+--- Find the collapsed block whose inline marker is under the mouse
+--- @return number|nil bufnr The buffer of the block
+--- @return table|nil block The block, or nil if the mouse is not over a marker
+function M.block_under_mouse()
+	local pos = vim.fn.getmousepos()
+	if pos.winid == 0 or pos.line == 0 then
+		return nil, nil
+	end
+
+	local bufnr = vim.api.nvim_win_get_buf(pos.winid)
+	local block = fold.get_block(bufnr, pos.line - 1)
+	if not block or not block.collapsed or block.start_row ~= pos.line - 1 then
+		return nil, nil
+	end
+
+	local vcol = utils.mouse_vcol(pos)
+	if not vcol then
+		return nil, nil
+	end
+
+	local first, last = utils.marker_vcols(pos.winid, bufnr, block)
+	if vcol < first or vcol > last then
+		return nil, nil
+	end
+
+	return bufnr, block
+end
+
+-- buffers with the mappings, so they can be removed when no-go is turned off for them
+M.buffers = {}
+
+-- the mouse configuration of the last setup
+local settings = nil
+
+--- Remove the mouse mappings of a buffer
+--- @param bufnr number The buffer number
+function M.detach(bufnr)
+	if not M.buffers[bufnr] then
+		return
+	end
+	M.buffers[bufnr] = nil
+
+	for _, key in ipairs({ settings.toggle, settings.peek }) do
+		if key and vim.api.nvim_buf_is_valid(bufnr) then
+			pcall(vim.keymap.del, "n", key, { buffer = bufnr })
+		end
+	end
+end
+
+--- Setup the mouse mappings of a Go buffer for its inline markers
+--- clicks and moves anywhere else are passed through untouched
+--- @param bufnr number The buffer number
+function M.attach(bufnr)
+	if not settings or M.buffers[bufnr] then
+		return
+	end
+	M.buffers[bufnr] = true
+
+	if settings.toggle then
+		local key = settings.toggle
+		vim.keymap.set("n", key, function()
+			-- a click on the scrollbar jumps to the mark on that row
+			local win, row = scrollbar.target_under_mouse()
+			if win then
+				vim.schedule(function()
+					vim.api.nvim_set_current_win(win)
+					vim.api.nvim_win_set_cursor(win, { row + 1, 0 })
+					vim.cmd("normal! zz")
+				end)
+				return ""
+			end
+
+			local target, block = M.block_under_mouse()
+			if not block then
+				return key
+			end
+
+			-- expr mappings can't touch the buffer, so toggle right after
+			vim.schedule(function()
+				peek.close()
+				fold.toggle_block(target, block, config.get(target))
+			end)
+			return ""
+		end, { buffer = bufnr, expr = true, desc = "no-go: toggle the block under the mouse" })
+	end
+
+	if settings.peek then
+		local key = settings.peek
+		vim.keymap.set("n", key, function()
+			local target, block = M.block_under_mouse()
+
+			-- windows can't be opened or closed from an expr mapping either
+			vim.schedule(function()
+				if block then
+					peek.open(target, block, "mouse")
+				else
+					peek.close()
+				end
+			end)
+			return key
+		end, { buffer = bufnr, expr = true, desc = "no-go: peek the block under the mouse" })
+	end
+end
+
+--- Setup mouse support, the mappings of the previous setup are removed
+--- Go buffers get theirs from attach() when no-go runs in them
+--- @param opts table The plugin configuration
+function M.setup(opts)
+	for bufnr in pairs(M.buffers) do
+		M.detach(bufnr)
+	end
+	settings = opts.mouse and opts.mouse.enabled and opts.mouse or nil
+end
+
+return M
@@ -0,0 +1,87 @@
+local M = {}
+
+-- the open peek float, if any
+M.win = nil
+
+-- buffer and row of the block shown in the float, so hovering the same marker doesn't reopen it
+M.key = nil
+
+-- the autocmds closing the float, one set at a time
+local augroup = "no-go-peek"
+
+--- Close the peek float if it is open
+function M.close()
+	if M.win and vim.api.nvim_win_is_valid(M.win) then
+		vim.api.nvim_win_close(M.win, true)
+	end
+	M.win = nil
+	M.key = nil
+	-- fails when the float was never opened, the group doesn't exist then
+	pcall(vim.api.nvim_del_augroup_by_name, augroup)
+end
+
+--- Check if the peek float is currently open
+--- @return boolean True if the float is open
+function M.is_open()
+	return M.win ~= nil and vim.api.nvim_win_is_valid(M.win)
+end
+
+--- Show the hidden lines of a block in a float
+--- @param bufnr number The buffer number
+--- @param block table The block to show
+--- @param relative string Where to anchor the float, "mouse" or "cursor"
+function M.open(bufnr, block, relative)
+	local key = bufnr .. ":" .. block.start_row
+	if M.is_open() and M.key == key then
+		return
+	end
+	M.close()
+
+	local lines = vim.api.nvim_buf_get_lines(bufnr, block.start_row + 1, block.end_row + 1, false)
+	if #lines == 0 then
+		return
+	end
+
+	-- strip the common indentation, the float has no surrounding code to line up with
+	local indent = nil
+	for _, line in ipairs(lines) do
+		if line:match("%S") then
+			local len = #line:match("^%s*")
+			indent = indent and math.min(indent, len) or len
+		end
+	end
+
+	local width = 1
+	for i, line in ipairs(lines) do
+		lines[i] = line:sub((indent or 0) + 1)
+		width = math.max(width, vim.fn.strdisplaywidth(lines[i]))
+	end
+
+	local buf = vim.api.nvim_create_buf(false, true)
+	vim.api.nvim_buf_set_lines(buf, 0, -1, false, lines)
+	vim.bo[buf].bufhidden = "wipe"
+	vim.bo[buf].tabstop = vim.bo[bufnr].tabstop
+
+	-- highlight without setting the filetype, so language servers don't attach to the scratch buffer
+	pcall(vim.treesitter.start, buf, "go")
+
+	M.win = vim.api.nvim_open_win(buf, false, {
+		relative = relative,
+		row = 1,
+		col = 0,
+		width = math.min(width, vim.o.columns - 4),
+		height = math.min(#lines, math.max(vim.o.lines - 4, 1)),
+		style = "minimal",
+		border = "rounded",
+		focusable = false,
+	})
+	M.key = key
+
+	vim.api.nvim_create_autocmd({ "CursorMoved", "CursorMovedI", "BufLeave" }, {
+		group = vim.api.nvim_create_augroup(augroup, { clear = true }),
+		once = true,
+		callback = M.close,
+	})
+end
+
+return M
@@ -1,15 +1,38 @@
 local M = {}
 
+-- the terminator of the block (return, panic, log.Fatal...) is checked in lua, see utils.find_terminator
 M.error_query = [[
 (
   (if_statement
     condition: (binary_expression
       left: (identifier) @err_identifier)
-    consequence: (block
-      (statement_list
-        (return_statement
-          (expression_list
-            (identifier) @return_identifier)?)))) @collapse_block) @if_statement
+    consequence: (block) @collapse_block) @if_statement
+)
+]]
+
+-- lazy initialisation: if X == nil { X = ... }, the zero value and the assignment are checked in lua
+M.lazy_query = [[
+(
+  (if_statement
+    !initializer
+    condition: (binary_expression
+      left: (_) @target
+      operator: "=="
+      right: (_) @zero)
+    consequence: (block) @collapse_block
+    !alternative) @if_statement
+)
+]]
+
+-- test assertions: if got != want { t.Errorf(...) }, the t.Error/t.Fatal call is checked in lua
+M.assert_query = [[
+(
+  (if_statement
+    !initializer
+    condition: (_) @condition
+    consequence: (block) @collapse_block
+    !alternative) @if_statement
+)
 ]]
 
 M.import_query = [[
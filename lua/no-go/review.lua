@@ -0,0 +1,289 @@
+local M = {}
+
+local fold = require("no-go.fold")
+local utils = require("no-go.utils")
+
+-- the review in progress:
+-- { root, path, files = { paths }, index = file under review, blocks = function(bufnr) -> blocks|nil,
+--   config, current = { bufnr, anchor, revealed } }
+M.session = nil
+
+-- what a block can be marked, and how the summary names it
+M.statuses = { ok = "ok", change = "to change", skip = "skipped" }
+
+--- Read a review file
+--- @param path string The review file
+--- @return table file -> { [anchor] = { status, note, line } }
+local function load(path)
+	if vim.fn.filereadable(path) == 0 then
+		return {}
+	end
+	local ok, data = pcall(function()
+		return vim.json.decode(table.concat(vim.fn.readfile(path), "\n"))
+	end)
+	if not ok or type(data) ~= "table" then
+		vim.notify("no-go.nvim: Can't read " .. path .. ", starting a new review", vim.log.levels.WARN)
+		return {}
+	end
+	return data
+end
+
+--- Write a review file
+--- @param path string The review file
+--- @param data table file -> { [anchor] = entry }
+local function save(path, data)
+	local ok, err = pcall(vim.fn.writefile, { vim.json.encode(data) }, path)
+	if not ok then
+		vim.notify("no-go.nvim: Can't save the review: " .. tostring(err), vim.log.levels.WARN)
+	end
+end
+
+--- The key of a file in the review file, relative to the module root
+--- @param root string The module root
+--- @param path string The file
+--- @return string The key
+local function file_key(root, path)
+	return vim.fs.relpath(root, path) or path
+end
+
+--- The blocks of a buffer that are error paths to review, imports aren't
+--- @param blocks table The blocks of the buffer
+--- @return table The blocks, in order
+local function reviewable(blocks)
+	local list = {}
+	for _, block in ipairs(blocks) do
+		if block.kind ~= "import" and block.anchor then
+			table.insert(list, block)
+		end
+	end
+	return list
+end
+
+--- Find a block of a buffer by its anchor
+--- @param bufnr number The buffer number
+--- @param anchor string The anchor
+--- @return table|nil The block
+local function find(bufnr, anchor)
+	for _, block in ipairs(fold.blocks[bufnr] or {}) do
+		if block.anchor == anchor then
+			return block
+		end
+	end
+	return nil
+end
+
+--- Collapse the block under review again, if the review revealed it
+local function leave()
+	local current = M.session.current
+	M.session.current = nil
+	if not current or not current.revealed or not vim.api.nvim_buf_is_valid(current.bufnr) then
+		return
+	end
+
+	local block = find(current.bufnr, current.anchor)
+	if block and block.revealed_by == "hand" then
+		fold.toggle_block(current.bufnr, block, M.session.config)
+	end
+end
+
+--- Reveal a block, put the cursor on its if line and center it
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @param position number Its place among the file's blocks
+--- @param total number The number of blocks in the file
+local function show(bufnr, block, position, total)
+	local session = M.session
+	if vim.api.nvim_get_current_buf() ~= bufnr then
+		vim.api.nvim_win_set_buf(0, bufnr)
+	end
+
+	local anchor = block.anchor
+	local revealed = false
+	if block.collapsed then
+		fold.toggle_block(bufnr, block, session.config)
+		revealed = true
+	end
+	session.current = { bufnr = bufnr, anchor = anchor, revealed = revealed }
+
+	vim.api.nvim_win_set_cursor(0, { block.start_row + 1, 0 })
+	vim.cmd("normal! zz")
+
+	vim.notify(
+		string.format(
+			"no-go.nvim: Review %d/%d of %s (%s), :NoGoReview ok, change {note} or skip",
+			position,
+			total,
+			file_key(session.root, vim.api.nvim_buf_get_name(bufnr)),
+			block.func_name or "top level"
+		),
+		vim.log.levels.INFO
+	)
+end
+
+--- Move to the next block without a mark, from the file under review on, or finish the review
+local function advance()
+	local session = M.session
+	local data = load(session.path)
+
+	while session.index <= #session.files do
+		local path = session.files[session.index]
+		local bufnr = vim.fn.bufadd(path)
+		vim.fn.bufload(bufnr)
+
+		local blocks = session.blocks(bufnr)
+		if blocks then
+			local marks = data[file_key(session.root, path)] or {}
+			local list = reviewable(blocks)
+			for i, block in ipairs(list) do
+				if not marks[block.anchor] then
+					show(bufnr, block, i, #list)
+					return
+				end
+			end
+		end
+		session.index = session.index + 1
+	end
+
+	M.session = nil
+	M.summary(session.root, session.path)
+end
+
+--- The files a review covers
+--- @param bufnr number The buffer
+--- @param package boolean|nil Every Go file of the buffer's directory, not only the buffer
+--- @return table The paths, sorted
+function M.files(bufnr, package)
+	local file = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	if not package then
+		return { file }
+	end
+	local files = vim.tbl_map(vim.fs.normalize, vim.fn.glob(vim.fs.dirname(file) .. "/*.go", false, true))
+	table.sort(files)
+	return files
+end
+
+--- The review file of a buffer's module
+--- @param bufnr number The buffer
+--- @param config table The plugin configuration
+--- @return string root The module root
+--- @return string path The review file
+function M.path(bufnr, config)
+	local root = utils.module_root(bufnr)
+	return root, root .. "/" .. config.review.file
+end
+
+--- Start (or resume) a review, blocks already marked in the review file are passed over
+--- @param bufnr number The buffer to start from
+--- @param package boolean|nil Review every Go file of the buffer's directory, not only the buffer
+--- @param blocks function(bufnr) -> blocks|nil, the up to date block index of a buffer, nil when no-go is off
+--- @param config table The plugin configuration
+function M.start(bufnr, package, blocks, config)
+	if M.session then
+		leave()
+	end
+
+	local file = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	local files = M.files(bufnr, package)
+	local root, path = M.path(bufnr, config)
+	M.session = {
+		root = root,
+		path = path,
+		files = files,
+		index = math.max(1, vim.fn.index(files, file) + 1),
+		blocks = blocks,
+		config = config,
+	}
+	advance()
+end
+
+--- Mark the block under review and move to the next one
+--- @param status string "ok", "change" or "skip"
+--- @param note string|nil What needs to change
+function M.mark(status, note)
+	local session = M.session
+	if not session or not session.current then
+		vim.notify("no-go.nvim: No review in progress, start one with :NoGoReview", vim.log.levels.WARN)
+		return
+	end
+
+	local current = session.current
+	local block = find(current.bufnr, current.anchor)
+	local data = load(session.path)
+	local key = file_key(session.root, vim.api.nvim_buf_get_name(current.bufnr))
+	data[key] = data[key] or {}
+	data[key][current.anchor] = {
+		status = status,
+		note = note ~= "" and note or nil,
+		line = block and block.start_row + 1 or nil,
+	}
+	save(session.path, data)
+
+	leave()
+	advance()
+end
+
+--- Stop the review, the marks so far stay in the review file
+function M.stop()
+	if M.session then
+		leave()
+		M.session = nil
+	end
+end
+
+--- Forget the marks of some files, to review them again
+--- @param root string The module root
+--- @param path string The review file
+--- @param files table The files
+function M.reset(root, path, files)
+	local data = load(path)
+	for _, file in ipairs(files) do
+		data[file_key(root, file)] = nil
+	end
+	save(path, data)
+end
+
+--- Report the review: the count of each mark, and the blocks to change or skipped in the quickfix list
+--- @param root string The module root
+--- @param path string The review file
+function M.summary(root, path)
+	local data = load(path)
+	local counts = { ok = 0, change = 0, skip = 0 }
+	local items = {}
+
+	for file, marks in pairs(data) do
+		for anchor, entry in pairs(marks) do
+			if counts[entry.status] then
+				counts[entry.status] = counts[entry.status] + 1
+			end
+			if entry.status == "change" or entry.status == "skip" then
+				table.insert(items, {
+					filename = root .. "/" .. file,
+					lnum = entry.line or 1,
+					text = M.statuses[entry.status] .. ": " .. (entry.note or anchor),
+				})
+			end
+		end
+	end
+	table.sort(items, function(a, b)
+		if a.filename ~= b.filename then
+			return a.filename < b.filename
+		end
+		return a.lnum < b.lnum
+	end)
+
+	local title = string.format(
+		"NoGoReview: %d ok, %d %s, %d %s",
+		counts.ok,
+		counts.change,
+		M.statuses.change,
+		counts.skip,
+		M.statuses.skip
+	)
+	vim.fn.setqflist({}, " ", { title = title, items = items })
+	vim.notify("no-go.nvim: " .. title, vim.log.levels.INFO)
+	if #items > 0 then
+		vim.cmd("copen")
+	end
+end
+
+return M
@@ -0,0 +1,203 @@
+local M = {}
+
+local lint = require("no-go.lint")
+
+M.namespace = vim.api.nvim_create_namespace("no-go-scrollbar")
+
+-- the float of each Go window, win -> { win, buf, targets }
+-- targets maps a scrollbar row (0-indexed) to the buffer row its mark jumps to
+M.bars = {}
+
+-- what a mark stands for, the higher priority wins when several share a row
+local kinds = {
+	revealed = { priority = 1, hl = "NoGoScrollbarRevealed" },
+	collapsed = { priority = 2, hl = "NoGoScrollbarCollapsed" },
+	lint = { priority = 3, hl = "NoGoScrollbarLint" },
+}
+
+--- Close the float of a window
+--- @param win number The window the float belongs to
+function M.close_win(win)
+	local bar = M.bars[win]
+	if not bar then
+		return
+	end
+	if vim.api.nvim_win_is_valid(bar.win) then
+		vim.api.nvim_win_close(bar.win, true)
+	end
+	M.bars[win] = nil
+end
+
+--- Close the floats of every window showing a buffer
+--- @param bufnr number The buffer number
+function M.close(bufnr)
+	for win, bar in pairs(M.bars) do
+		if not vim.api.nvim_win_is_valid(win) or vim.api.nvim_win_get_buf(win) == bufnr or bar.bufnr == bufnr then
+			M.close_win(win)
+		end
+	end
+end
+
+--- Collect the marks of a buffer: blocks by state, and lint findings
+--- @param bufnr number The buffer number
+--- @param blocks table The blocks of the buffer
+--- @return table List of { row, kind }
+local function collect_marks(bufnr, blocks)
+	local marks = {}
+	for _, block in ipairs(blocks) do
+		table.insert(marks, { row = block.start_row, kind = block.collapsed and "collapsed" or "revealed" })
+	end
+
+	local findings = lint.marks[bufnr] or {}
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, lint.namespace, 0, -1, {})) do
+		if findings[mark[1]] then
+			table.insert(marks, { row = mark[2], kind = "lint" })
+		end
+	end
+	return marks
+end
+
+--- Open the float of a window, or move it where the window is now
+--- @param win number The Go window
+--- @param height number The height of the window
+--- @param width number The width of the scrollbar
+--- @return table The bar
+local function place(win, height, width)
+	local bar = M.bars[win]
+	local float_config = {
+		relative = "win",
+		win = win,
+		row = 0,
+		col = vim.api.nvim_win_get_width(win) - width,
+		width = width,
+		height = height,
+	}
+
+	if bar and vim.api.nvim_win_is_valid(bar.win) then
+		vim.api.nvim_win_set_config(bar.win, float_config)
+		return bar
+	end
+
+	local buf = vim.api.nvim_create_buf(false, true)
+	vim.bo[buf].bufhidden = "wipe"
+
+	float_config.style = "minimal"
+	float_config.focusable = false
+	float_config.noautocmd = true
+	-- under other floats (peek, completion)
+	float_config.zindex = 10
+	bar = { win = vim.api.nvim_open_win(buf, false, float_config), buf = buf, targets = {} }
+	vim.wo[bar.win].winhighlight = "Normal:NoGoScrollbar"
+	M.bars[win] = bar
+	return bar
+end
+
+--- Draw the scrollbar of a window: the visible part of the buffer, and a mark per block and finding
+--- at its place in proportion to the whole buffer
+--- @param win number The window
+--- @param bufnr number The buffer shown in the window
+--- @param blocks table The blocks of the buffer
+--- @param config table The plugin configuration
+local function draw(win, bufnr, blocks, config)
+	local opts = config.scrollbar
+	local height = vim.api.nvim_win_get_height(win)
+	if vim.api.nvim_win_get_width(win) <= opts.width * 4 or height < 1 then
+		M.close_win(win)
+		return
+	end
+
+	local line_count = vim.api.nvim_buf_line_count(bufnr)
+	local function to_bar(row)
+		return math.min(math.floor(row * height / line_count), height - 1)
+	end
+
+	-- the mark that wins each row
+	local rows = {}
+	for _, mark in ipairs(collect_marks(bufnr, blocks)) do
+		local bar_row = to_bar(mark.row)
+		local current = rows[bar_row]
+		if not current or kinds[mark.kind].priority > kinds[current.kind].priority then
+			rows[bar_row] = mark
+		end
+	end
+
+	local bar = place(win, height, opts.width)
+	bar.bufnr = bufnr
+	bar.targets = {}
+
+	local lines = {}
+	for i = 0, height - 1 do
+		local mark = rows[i]
+		lines[i + 1] = mark and string.rep(opts[mark.kind], opts.width) or string.rep(" ", opts.width)
+		bar.targets[i] = mark and mark.row
+	end
+	vim.api.nvim_buf_set_lines(bar.buf, 0, -1, false, lines)
+
+	vim.api.nvim_buf_clear_namespace(bar.buf, M.namespace, 0, -1)
+	local top, bottom = vim.fn.line("w0", win) - 1, vim.fn.line("w$", win) - 1
+	for i = to_bar(top), to_bar(bottom) do
+		vim.api.nvim_buf_set_extmark(bar.buf, M.namespace, i, 0, { line_hl_group = "NoGoScrollbarView" })
+	end
+	for i, mark in pairs(rows) do
+		vim.api.nvim_buf_set_extmark(bar.buf, M.namespace, i, 0, {
+			end_col = #lines[i + 1],
+			hl_group = kinds[mark.kind].hl,
+			priority = 200,
+		})
+	end
+end
+
+--- Redraw the scrollbars of every window showing a buffer
+--- @param bufnr number The buffer number
+--- @param blocks table|nil The blocks of the buffer, nil closes its scrollbars
+--- @param config table The plugin configuration
+function M.update(bufnr, blocks, config)
+	if not config.scrollbar or not config.scrollbar.enabled or not blocks then
+		M.close(bufnr)
+		return
+	end
+
+	for _, win in ipairs(vim.fn.win_findbuf(bufnr)) do
+		-- floats get no scrollbar of their own
+		if vim.api.nvim_win_get_config(win).relative == "" then
+			draw(win, bufnr, blocks, config)
+		end
+	end
+
+	M.prune()
+end
+
+--- Close the floats of windows that were closed or moved on to another buffer
+function M.prune()
+	for win, bar in pairs(M.bars) do
+		if not vim.api.nvim_win_is_valid(win) or vim.api.nvim_win_get_buf(win) ~= bar.bufnr then
+			M.close_win(win)
+		end
+	end
+end
+
+--- Find the buffer row the scrollbar under the mouse points to
+--- @return number|nil win The window of the scrollbar
+--- @return number|nil row The buffer row (0-indexed), of the mark on that row or in proportion
+function M.target_under_mouse()
+	local pos = vim.fn.getmousepos()
+	-- the float isn't focusable, so the mouse reports the window under it
+	local bar = M.bars[pos.winid]
+	if not bar or not vim.api.nvim_win_is_valid(bar.win) or pos.winrow == 0 then
+		return nil, nil
+	end
+	if pos.wincol <= vim.api.nvim_win_get_width(pos.winid) - vim.api.nvim_win_get_width(bar.win) then
+		return nil, nil
+	end
+
+	local bar_row = pos.winrow - 1
+	local row = bar.targets[bar_row]
+	if not row then
+		local line_count = vim.api.nvim_buf_line_count(vim.api.nvim_win_get_buf(pos.winid))
+		local height = vim.api.nvim_win_get_height(bar.win)
+		row = math.floor(bar_row * line_count / height)
+	end
+	return pos.winid, row
+end
+
+return M
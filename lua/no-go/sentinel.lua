@@ -0,0 +1,364 @@
+local M = {}
+
+local fold = require("no-go.fold")
+local utils = require("no-go.utils")
+
+-- selector calls, errors.New("...") is checked in lua
+local call_query = [[
+(call_expression
+  function: (selector_expression
+    operand: (identifier) @package
+    field: (field_identifier) @function)
+  arguments: (argument_list) @arguments) @call
+]]
+
+-- every use of the errors package, to know when its import goes unused
+local errors_query = [[
+(selector_expression
+  operand: (identifier) @package
+  (#eq? @package "errors")) @selector
+]]
+
+local parsed = {}
+
+--- Parse (once) a query of this module
+--- @param text string The query
+--- @return vim.treesitter.Query The query
+local function query(text)
+	parsed[text] = parsed[text] or vim.treesitter.query.parse("go", text)
+	return parsed[text]
+end
+
+--- The string literal of an errors.New call
+--- @param call TSNode The call_expression node
+--- @param source number|string The buffer number or source string
+--- @return string|nil The literal, quotes included
+local function new_literal(call, source)
+	local func = call:field("function")[1]
+	if not func or func:type() ~= "selector_expression" then
+		return nil
+	end
+	if vim.treesitter.get_node_text(func, source) ~= "errors.New" then
+		return nil
+	end
+
+	local args = call:field("arguments")[1]
+	local literal = args and args:named_child_count() == 1 and args:named_child(0)
+	if not literal then
+		return nil
+	end
+	local type = literal:type()
+	if type ~= "interpreted_string_literal" and type ~= "raw_string_literal" then
+		return nil
+	end
+	return vim.treesitter.get_node_text(literal, source)
+end
+
+--- The sentinel name of a message: "invalid email" becomes ErrInvalidEmail
+--- @param literal string The string literal, quotes included
+--- @return string|nil The name, nil when the message has no letters or digits
+function M.name(literal)
+	local words = {}
+	for word in literal:sub(2, -2):gsub("\\.", " "):gmatch("%w+") do
+		table.insert(words, word:sub(1, 1):upper() .. word:sub(2))
+	end
+	if #words == 0 then
+		return nil
+	end
+	return "Err" .. table.concat(words)
+end
+
+--- The package name of a parsed file
+--- @param root TSNode The root of the file's tree
+--- @param source number|string The buffer number or source string
+--- @return string|nil The package name
+local function package_name(root, source)
+	for child in root:iter_children() do
+		if child:type() == "package_clause" then
+			local name = child:named_child(0)
+			return name and vim.treesitter.get_node_text(name, source)
+		end
+	end
+	return nil
+end
+
+--- The top level names a file declares, with the value of single value vars
+--- @param root TSNode The root of the file's tree
+--- @param source number|string The buffer number or source string
+--- @param names table name -> value text (or true), added to
+local function declared(root, source, names)
+	local function add(spec)
+		local value = spec:field("value")[1]
+		for _, name in ipairs(spec:field("name")) do
+			names[vim.treesitter.get_node_text(name, source)] = value
+					and value:named_child_count() == 1
+					and vim.treesitter.get_node_text(value:named_child(0), source)
+				or true
+		end
+	end
+
+	local function walk(node)
+		for child in node:iter_children() do
+			local type = child:type()
+			if type == "var_spec" or type == "const_spec" or type == "type_spec" or type == "type_alias" then
+				add(child)
+			elseif type == "var_spec_list" or type == "const_spec_list" then
+				walk(child)
+			end
+		end
+	end
+
+	for child in root:iter_children() do
+		local type = child:type()
+		if type == "function_declaration" then
+			names[vim.treesitter.get_node_text(child:field("name")[1], source)] = true
+		elseif type == "var_declaration" or type == "const_declaration" or type == "type_declaration" then
+			walk(child)
+		end
+	end
+end
+
+--- The closing paren of a top level var (...) group holding errors.New values
+--- @param root TSNode The root of the file's tree
+--- @param source number|string The buffer number or source string
+--- @return TSNode|nil paren The ")" node
+--- @return string indent The indentation of the group's specs
+local function error_group(root, source)
+	for child in root:iter_children() do
+		if child:type() == "var_declaration" then
+			local container = child
+			for sub in child:iter_children() do
+				if sub:type() == "var_spec_list" then
+					container = sub
+				end
+			end
+
+			local paren, holds_errors, indent = nil, false, "\t"
+			for spec in container:iter_children() do
+				if spec:type() == ")" then
+					paren = spec
+				elseif spec:type() == "var_spec" then
+					local value = spec:field("value")[1]
+					local call = value and value:named_child(0)
+					if call and call:type() == "call_expression" and new_literal(call, source) then
+						holds_errors = true
+						local row, col = spec:start()
+						local line = type(source) == "number"
+								and vim.api.nvim_buf_get_lines(source, row, row + 1, false)[1]
+							or vim.split(source, "\n")[row + 1]
+						indent = (line or ""):sub(1, col)
+					end
+				end
+			end
+			if paren and holds_errors then
+				return paren, indent
+			end
+		end
+	end
+	return nil, "\t"
+end
+
+--- Where a new var goes when there's no group: after the imports, or the package clause
+--- @param root TSNode The root of the file's tree
+--- @return number The row to insert at (0-indexed)
+local function after_imports(root)
+	local row = 0
+	for child in root:iter_children() do
+		local type = child:type()
+		if type == "package_clause" or type == "import_declaration" then
+			row = child:end_() + 1
+		end
+	end
+	return row
+end
+
+--- The edit removing the errors import of a file
+--- @param root TSNode The root of the file's tree
+--- @param source number|string The buffer number or source string
+--- @return table|nil The text edit
+local function remove_import(root, source)
+	for child in root:iter_children() do
+		if child:type() == "import_declaration" then
+			local specs = {}
+			local function walk(node)
+				for sub in node:iter_children() do
+					if sub:type() == "import_spec" then
+						table.insert(specs, sub)
+					elseif sub:type() == "import_spec_list" then
+						walk(sub)
+					end
+				end
+			end
+			walk(child)
+
+			for _, spec in ipairs(specs) do
+				local path = spec:field("path")[1]
+				if path and vim.treesitter.get_node_text(path, source) == '"errors"' then
+					-- the last import of the declaration takes the declaration with it
+					local node = #specs == 1 and child or spec
+					local start_row, _, end_row = node:range()
+					return {
+						range = { start = { line = start_row, character = 0 }, ["end"] = { line = end_row + 1, character = 0 } },
+						newText = "",
+					}
+				end
+			end
+		end
+	end
+	return nil
+end
+
+--- The Go files of a buffer's package, the buffer first
+--- @param bufnr number The buffer number
+--- @return table List of { path, root, source }
+local function package_files(bufnr)
+	local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	local root, source = utils.parse_file(path)
+	if not root then
+		return {}
+	end
+	local files = { { path = path, root = root, source = source } }
+	local name = package_name(root, source)
+
+	for _, other in ipairs(vim.fn.glob(vim.fs.dirname(path) .. "/*.go", false, true)) do
+		other = vim.fs.normalize(other)
+		if other ~= path then
+			local other_root, other_source = utils.parse_file(other)
+			if other_root and package_name(other_root, other_source) == name then
+				table.insert(files, { path = other, root = other_root, source = other_source })
+			end
+		end
+	end
+	return files
+end
+
+--- The errors.New call of a block's body
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @return string|nil The literal, quotes included
+local function block_literal(bufnr, block)
+	local body = fold.body_node(block)
+	if not body then
+		return nil
+	end
+	local q = query(call_query)
+	for _, node in q:iter_captures(body, bufnr, 0, -1) do
+		if node:type() == "call_expression" then
+			local literal = new_literal(node, bufnr)
+			if literal then
+				return literal
+			end
+		end
+	end
+	return nil
+end
+
+--- The code action extracting the errors.New literal of the block at a row into a package sentinel
+--- The var joins a var (...) group of errors.New values when the package has one, and every
+--- errors.New with the same literal in the package functions is replaced
+--- @param bufnr number The buffer number
+--- @param row number The row (0-indexed)
+--- @return table|nil The code action, with its workspace edit
+function M.action(bufnr, row)
+	-- the block index is stale after an edit, no action until it's processed again
+	if fold.ticks[bufnr] ~= vim.api.nvim_buf_get_changedtick(bufnr) then
+		return nil
+	end
+	local block = fold.get_block(bufnr, row)
+	if not block or block.kind ~= "error" then
+		return nil
+	end
+
+	local literal = block_literal(bufnr, block)
+	local name = literal and M.name(literal)
+	if not name then
+		return nil
+	end
+
+	local files = package_files(bufnr)
+	local names = {}
+	for _, file in ipairs(files) do
+		declared(file.root, file.source, names)
+	end
+	-- a sentinel of that name with another value is somebody else's
+	local existing = names[name]
+	if existing and existing ~= "errors.New(" .. literal .. ")" then
+		return nil
+	end
+
+	local changes = {}
+	local receiving = nil
+	if not existing then
+		for _, file in ipairs(files) do
+			local paren, indent = error_group(file.root, file.source)
+			if paren then
+				local paren_row = paren:start()
+				receiving = file.path
+				changes[vim.uri_from_fname(file.path)] = {
+					{
+						range = { start = { line = paren_row, character = 0 }, ["end"] = { line = paren_row, character = 0 } },
+						newText = string.format("%s%s = errors.New(%s)\n", indent, name, literal),
+					},
+				}
+				break
+			end
+		end
+		if not receiving then
+			local file = files[1]
+			local insert_row = after_imports(file.root)
+			receiving = file.path
+			changes[vim.uri_from_fname(file.path)] = {
+				{
+					range = { start = { line = insert_row, character = 0 }, ["end"] = { line = insert_row, character = 0 } },
+					newText = string.format("\nvar %s = errors.New(%s)\n", name, literal),
+				},
+			}
+		end
+	end
+
+	local q = query(call_query)
+	local uses = query(errors_query)
+	for _, file in ipairs(files) do
+		local edits = changes[vim.uri_from_fname(file.path)] or {}
+		local replaced = 0
+		for _, node in q:iter_captures(file.root, file.source, 0, -1) do
+			if
+				node:type() == "call_expression"
+				and new_literal(node, file.source) == literal
+				and utils.enclosing_function(node, file.source)
+			then
+				local start_row, start_col, end_row, end_col = node:range()
+				table.insert(edits, {
+					range = {
+						start = { line = start_row, character = start_col },
+						["end"] = { line = end_row, character = end_col },
+					},
+					newText = name,
+				})
+				replaced = replaced + 1
+			end
+		end
+
+		if replaced > 0 then
+			-- the file's last use of the errors package goes, and its import with it
+			local used = 0
+			for _ in uses:iter_matches(file.root, file.source, 0, -1) do
+				used = used + 1
+			end
+			local unused = used == replaced and file.path ~= receiving and remove_import(file.root, file.source)
+			if unused then
+				table.insert(edits, unused)
+			end
+			changes[vim.uri_from_fname(file.path)] = edits
+		end
+	end
+
+	return {
+		title = existing and string.format("Use %s for %s", name, literal)
+			or string.format("Extract %s into %s", literal, name),
+		kind = "refactor.extract",
+		edit = { changes = changes },
+	}
+end
+
+return M
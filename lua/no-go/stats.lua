@@ -0,0 +1,315 @@
+local M = {}
+
+local fold = require("no-go.fold")
+local utils = require("no-go.utils")
+
+-- how many of the largest blocks the dashboard lists
+local largest_count = 5
+
+-- exit classes, in the order the dashboard shows them
+M.classes = { "wrapped", "bare", "swallowed", "fatal" }
+
+--- Check if a call name ends the program (or the test) instead of returning
+--- @param name string The called function (e.g. "log.Fatalf")
+--- @return boolean True for panic, os.Exit, log.Fatal*, t.Fatal* and the like
+local function is_fatal_call(name)
+	return name == "panic"
+		or name == "os.Exit"
+		or name:match("%.Fatal%a*$") ~= nil
+		or name:match("%.Panic%a*$") ~= nil
+end
+
+--- Walk a node and call fn with every call_expression name found
+--- @param node TSNode The node to walk
+--- @param bufnr number The buffer number
+--- @param fn function Called with the call node and its function text
+local function each_call(node, bufnr, fn)
+	for child in node:iter_children() do
+		if child:type() == "call_expression" then
+			local func = child:field("function")[1]
+			if func then
+				fn(child, vim.treesitter.get_node_text(func, bufnr))
+			end
+		end
+		each_call(child, bufnr, fn)
+	end
+end
+
+--- Check if a node references an identifier, keys of composite literals aside
+--- @param node TSNode The node to walk
+--- @param bufnr number The buffer number
+--- @param name string The identifier
+--- @return boolean True if the identifier appears in the node
+local function references(node, bufnr, name)
+	if node:type() == "identifier" then
+		return vim.treesitter.get_node_text(node, bufnr) == name
+	end
+	for child in node:iter_children() do
+		-- the Err of &PathError{Err: err} is a field name, not the error
+		local key = node:type() == "keyed_element" and child == node:named_child(0)
+		if not key and references(child, bufnr, name) then
+			return true
+		end
+	end
+	return false
+end
+
+--- Classify how an error block exits
+--- wrapped: returns an expression built from the error (fmt.Errorf("...: %v", err), &PathError{Err: err},
+--- errors.Wrap...)
+--- bare: returns the tested identifier as is
+--- swallowed: leaves without passing the error on
+--- fatal: panics or exits
+--- @param block table The error block (needs body and err_node)
+--- @param bufnr number The buffer number
+--- @return string The exit class
+function M.classify(block, bufnr)
+	local fatal = false
+	each_call(block.body, bufnr, function(_, name)
+		if is_fatal_call(name) then
+			fatal = true
+		end
+	end)
+	if fatal then
+		return "fatal"
+	end
+
+	local name = block.err_node and vim.treesitter.get_node_text(block.err_node, bufnr)
+	for _, statement in ipairs(utils.block_statements(block.body)) do
+		if statement:type() == "return_statement" then
+			local values = statement:named_child(0)
+			if values then
+				local class = "swallowed"
+				for value in values:iter_children() do
+					if value:type() == "identifier" and vim.treesitter.get_node_text(value, bufnr) == name then
+						class = "bare"
+					elseif value:named() and name and references(value, bufnr, name) then
+						return "wrapped"
+					elseif value:type() == "call_expression" then
+						local text = vim.treesitter.get_node_text(value, bufnr)
+						local wraps = text:match("^[%w_.]*Wrap") or text:match("^[%w_.]*WithMessage")
+						if text:find("%w", 1, true) or wraps then
+							return "wrapped"
+						end
+					end
+				end
+				return class
+			end
+			return "swallowed"
+		end
+	end
+	return "swallowed"
+end
+
+--- Name of a function or method node, methods are prefixed with their receiver type
+--- @param node TSNode The function_declaration or method_declaration node
+--- @param bufnr number The buffer number
+--- @return string The name
+local function function_name(node, bufnr)
+	local name_node = node:field("name")[1]
+	local name = name_node and vim.treesitter.get_node_text(name_node, bufnr) or "func"
+
+	local receiver = node:field("receiver")[1]
+	if receiver then
+		local type = vim.treesitter.get_node_text(receiver, bufnr):match("([%w_]+)%s*%)$")
+		if type then
+			name = type .. "." .. name
+		end
+	end
+	return name
+end
+
+--- Gather the dashboard data from the blocks process_buffer found
+--- @param bufnr number The buffer number
+--- @return table { functions, totals, hidden, largest }
+function M.collect(bufnr)
+	local blocks = vim.tbl_filter(function(block)
+		return block.kind == "error"
+	end, fold.blocks[bufnr] or {})
+
+	local parser = vim.treesitter.get_parser(bufnr, "go")
+	local root = parser:parse()[1]:root()
+
+	local functions = {}
+	for child in root:iter_children() do
+		if child:type() == "function_declaration" or child:type() == "method_declaration" then
+			local start_row, _, end_row = child:range()
+			local entry = { name = function_name(child, bufnr), row = start_row, error_lines = 0, exits = {} }
+			for _, class in ipairs(M.classes) do
+				entry.exits[class] = 0
+			end
+
+			for _, block in ipairs(blocks) do
+				if block.start_row >= start_row and block.end_row <= end_row then
+					-- lines of nested blocks are already counted by their outermost block
+					local nested = false
+					for _, other in ipairs(blocks) do
+						if other ~= block and other.start_row < block.start_row and other.end_row >= block.end_row then
+							nested = true
+						end
+					end
+					if not nested then
+						entry.error_lines = entry.error_lines + block.end_row - block.start_row + 1
+					end
+
+					local class = M.classify(block, bufnr)
+					entry.exits[class] = entry.exits[class] + 1
+				end
+			end
+
+			-- the signature and closing brace lines are neither
+			entry.logic_lines = math.max(end_row - start_row - 1 - entry.error_lines, 0)
+			table.insert(functions, entry)
+		end
+	end
+
+	local totals = { error_lines = 0, logic_lines = 0, exits = {} }
+	for _, class in ipairs(M.classes) do
+		totals.exits[class] = 0
+	end
+	for _, entry in ipairs(functions) do
+		totals.error_lines = totals.error_lines + entry.error_lines
+		totals.logic_lines = totals.logic_lines + entry.logic_lines
+		for _, class in ipairs(M.classes) do
+			totals.exits[class] = totals.exits[class] + entry.exits[class]
+		end
+	end
+
+	local hidden = 0
+	local all = fold.blocks[bufnr] or {}
+	for _, block in ipairs(all) do
+		if block.collapsed then
+			-- the lines of a collapsed block inside another one are already hidden by it
+			local inside = false
+			for _, other in ipairs(all) do
+				if
+					other ~= block
+					and other.collapsed
+					and other.start_row < block.start_row
+					and other.end_row >= block.end_row
+				then
+					inside = true
+				end
+			end
+			if not inside then
+				hidden = hidden + block.end_row - block.start_row
+			end
+		end
+	end
+
+	local largest = vim.list_extend({}, blocks)
+	table.sort(largest, function(a, b)
+		return a.end_row - a.start_row > b.end_row - b.start_row
+	end)
+	largest = vim.list_slice(largest, 1, largest_count)
+
+	return { functions = functions, totals = totals, hidden = hidden, largest = largest }
+end
+
+--- Render the dashboard
+--- @param data table The data, from collect()
+--- @return table lines The lines
+--- @return table targets Line number (1-indexed) -> row to jump to (0-indexed)
+function M.render(data)
+	local header = string.format(
+		"%-32s %6s %6s %7s %5s %9s %5s",
+		"function",
+		"error",
+		"logic",
+		"wrapped",
+		"bare",
+		"swallowed",
+		"fatal"
+	)
+	local lines = { header, string.rep("─", vim.fn.strdisplaywidth(header)) }
+	local targets = {}
+
+	local function row(name, entry)
+		return string.format(
+			"%-32s %6d %6d %7d %5d %9d %5d",
+			name,
+			entry.error_lines,
+			entry.logic_lines,
+			entry.exits.wrapped,
+			entry.exits.bare,
+			entry.exits.swallowed,
+			entry.exits.fatal
+		)
+	end
+
+	for _, entry in ipairs(data.functions) do
+		table.insert(lines, row(entry.name, entry))
+		targets[#lines] = entry.row
+	end
+
+	table.insert(lines, string.rep("─", vim.fn.strdisplaywidth(header)))
+	table.insert(lines, row("total", data.totals))
+	table.insert(lines, "")
+	table.insert(lines, string.format("%d lines hidden", data.hidden))
+
+	if #data.largest > 0 then
+		table.insert(lines, "")
+		table.insert(lines, "largest blocks")
+		for _, block in ipairs(data.largest) do
+			local size = block.end_row - block.start_row + 1
+			table.insert(lines, string.format("  line %-6d %3d lines  %s", block.start_row + 1, size, block.func_name or ""))
+			targets[#lines] = block.start_row
+		end
+	end
+
+	return lines, targets
+end
+
+--- Open the dashboard for a buffer, <CR> on a row jumps to its function or block
+--- @param bufnr number The buffer number
+function M.open(bufnr)
+	local source_win = vim.api.nvim_get_current_win()
+	local lines, targets = M.render(M.collect(bufnr))
+
+	local width = 1
+	for _, line in ipairs(lines) do
+		width = math.max(width, vim.fn.strdisplaywidth(line))
+	end
+
+	local buf = vim.api.nvim_create_buf(false, true)
+	vim.api.nvim_buf_set_lines(buf, 0, -1, false, lines)
+	vim.bo[buf].modifiable = false
+	vim.bo[buf].bufhidden = "wipe"
+
+	local height = math.min(#lines, math.max(vim.o.lines - 6, 1))
+	local win = vim.api.nvim_open_win(buf, true, {
+		relative = "editor",
+		row = math.floor((vim.o.lines - height) / 2) - 1,
+		col = math.floor((vim.o.columns - width) / 2),
+		width = math.min(width, vim.o.columns - 4),
+		height = height,
+		style = "minimal",
+		border = "rounded",
+		title = " no-go: " .. vim.fn.fnamemodify(vim.api.nvim_buf_get_name(bufnr), ":t") .. " ",
+	})
+	vim.wo[win].cursorline = true
+	vim.api.nvim_win_set_cursor(win, { math.min(3, #lines), 0 })
+
+	local function close()
+		if vim.api.nvim_win_is_valid(win) then
+			vim.api.nvim_win_close(win, true)
+		end
+	end
+
+	vim.keymap.set("n", "<CR>", function()
+		local target = targets[vim.fn.line(".")]
+		if not target then
+			return
+		end
+		close()
+		if vim.api.nvim_win_is_valid(source_win) then
+			vim.api.nvim_set_current_win(source_win)
+			vim.api.nvim_win_set_cursor(source_win, { target + 1, 0 })
+			vim.cmd("normal! zz")
+		end
+	end, { buffer = buf, desc = "no-go: jump to the function or block" })
+	vim.keymap.set("n", "q", close, { buffer = buf, desc = "no-go: close the dashboard" })
+	vim.keymap.set("n", "<Esc>", close, { buffer = buf, desc = "no-go: close the dashboard" })
+end
+
+return M
@@ -0,0 +1,298 @@
+local M = {}
+
+-- anchors on the lines failing tests went through, extmarks so they follow edits
+M.namespace = vim.api.nvim_create_namespace("no-go-tests")
+
+-- failing test names per anchor, bufnr -> { [mark_id] = { "TestA", ... } }
+M.names = {}
+
+-- locations in files that are not loaded yet, path -> { [row] = { "TestA", ... } }
+M.pending = {}
+
+--- Parse `go test -json` output into the file:line locations of failing tests
+--- Locations come from the test's own output (t.Errorf lines) and from panic stack traces
+--- @param lines table Lines of `go test -json` output
+--- @return table List of { package, test, file, line }
+function M.parse(lines)
+	local output = {}
+	local failed = {}
+	local failed_packages = {}
+
+	for _, line in ipairs(lines) do
+		local ok, event = pcall(vim.json.decode, line)
+		if ok and type(event) == "table" and event.Action then
+			local key = (event.Package or "") .. " " .. (event.Test or "")
+			if event.Action == "output" and event.Output then
+				output[key] = output[key] or {}
+				table.insert(output[key], event.Output)
+			elseif event.Action == "fail" then
+				table.insert(failed, { package = event.Package, test = event.Test, key = key })
+				if event.Test and event.Package then
+					failed_packages[event.Package] = true
+				end
+			end
+		end
+	end
+
+	local locations = {}
+	for _, failure in ipairs(failed) do
+		-- a package fails whenever one of its tests does, only keep it when no test is to blame
+		-- (a panic in TestMain or init)
+		if failure.test or not failed_packages[failure.package] then
+			for _, text in ipairs(output[failure.key] or {}) do
+				for file, lnum in text:gmatch("([%w_%-%.%/]*%.go):(%d+)") do
+					table.insert(locations, {
+						package = failure.package,
+						test = failure.test or failure.package,
+						file = file,
+						line = tonumber(lnum),
+					})
+				end
+			end
+		end
+	end
+
+	return locations
+end
+
+--- Map the import paths of a module to their directories, with `go list` run in the background
+--- @param root string The module root
+--- @param on_done function Called with import path -> directory (empty when go list fails)
+local function package_dirs(root, on_done)
+	local function done(result)
+		local dirs = {}
+		if result and result.code == 0 then
+			for _, line in ipairs(vim.split(result.stdout or "", "\n", { trimempty = true })) do
+				local import_path, dir = line:match("^(.-)\t(.*)$")
+				if import_path then
+					dirs[import_path] = dir
+				end
+			end
+		end
+		vim.schedule(function()
+			on_done(dirs)
+		end)
+	end
+
+	-- vim.system raises when go isn't installed
+	local ok = pcall(vim.system, { "go", "list", "-f", "{{.ImportPath}}\t{{.Dir}}", "./..." }, {
+		cwd = root,
+		text = true,
+		timeout = 10000,
+	}, done)
+	if not ok then
+		done(nil)
+	end
+end
+
+--- Resolve a file name from test output to an absolute path inside the module
+--- @param location table The location, from parse()
+--- @param dirs table Import path -> directory
+--- @param root string The module root
+--- @return string|nil The absolute path, or nil for files outside the module (runtime, testing)
+local function resolve(location, dirs, root)
+	local file = location.file
+	if file:sub(1, 1) == "/" then
+		file = vim.fs.normalize(file)
+		if vim.startswith(file, root .. "/") then
+			return file
+		end
+		return nil
+	end
+
+	local dir = dirs[location.package]
+	if dir then
+		return vim.fs.normalize(dir .. "/" .. file)
+	end
+
+	-- no package directory, look for a loaded buffer with that name
+	for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
+		local name = vim.api.nvim_buf_get_name(bufnr)
+		if vim.endswith(name, "/" .. file) and vim.startswith(name, root .. "/") then
+			return vim.fs.normalize(name)
+		end
+	end
+	return nil
+end
+
+--- Add a test name to a list if it is not there yet
+--- @param names table The list
+--- @param name string The test name
+local function add_name(names, name)
+	if not vim.tbl_contains(names, name) then
+		table.insert(names, name)
+	end
+end
+
+--- Turn the pending locations of a file into anchors once its buffer is loaded
+--- @param bufnr number The buffer number
+local function place_pending(bufnr)
+	local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	local rows = M.pending[path]
+	if not rows then
+		return
+	end
+	M.pending[path] = nil
+
+	local line_count = vim.api.nvim_buf_line_count(bufnr)
+	M.names[bufnr] = M.names[bufnr] or {}
+	for row, names in pairs(rows) do
+		if row < line_count then
+			local id = vim.api.nvim_buf_set_extmark(bufnr, M.namespace, row, 0, {})
+			M.names[bufnr][id] = names
+		end
+	end
+end
+
+--- Attach failing test names to the blocks of a buffer, called before the blocks are collapsed
+--- A location inside an error block points at that block, a location anywhere else in a
+--- function (a call in a panic trace) points at every error block of that function
+--- @param bufnr number The buffer number
+--- @param blocks table The located blocks, sorted by start_row
+function M.update(bufnr, blocks)
+	place_pending(bufnr)
+
+	local names = M.names[bufnr]
+	if not names then
+		return
+	end
+
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, M.namespace, 0, -1, {})) do
+		local id, row = mark[1], mark[2]
+		local tests = names[id] or {}
+
+		local innermost = nil
+		for _, block in ipairs(blocks) do
+			if block.kind == "error" and row >= block.start_row and row <= block.end_row then
+				if not innermost or block.start_row > innermost.start_row then
+					innermost = block
+				end
+			end
+		end
+
+		local targets = { innermost }
+		if not innermost then
+			targets = vim.tbl_filter(function(block)
+				return block.kind == "error"
+					and block.func_start_row ~= nil
+					and row >= block.func_start_row
+					and row <= block.func_end_row
+			end, blocks)
+		end
+
+		for _, block in ipairs(targets) do
+			block.tests = block.tests or {}
+			for _, test in ipairs(tests) do
+				add_name(block.tests, test)
+			end
+		end
+	end
+end
+
+--- Forget every test result and its anchors
+function M.clear()
+	for bufnr in pairs(M.names) do
+		if vim.api.nvim_buf_is_valid(bufnr) then
+			vim.api.nvim_buf_clear_namespace(bufnr, M.namespace, 0, -1)
+		end
+	end
+	M.names = {}
+	M.pending = {}
+end
+
+--- Load `go test -json` output, replacing the previous results
+--- The package directories come from `go list`, so the results are placed once it's done
+--- @param lines table Lines of `go test -json` output
+--- @param root string The module root, locations outside of it are ignored
+--- @param on_done function Called with the number of failing tests with a location in the module,
+--- and the loaded buffers whose results changed
+function M.load(lines, root, on_done)
+	local locations = M.parse(lines)
+	package_dirs(root, function(dirs)
+		on_done(M.place(locations, dirs, root))
+	end)
+end
+
+--- Place the failing test locations, replacing the previous results
+--- @param locations table The locations, from parse()
+--- @param dirs table Import path -> directory
+--- @param root string The module root, locations outside of it are ignored
+--- @return number count The number of failing tests with a location in the module
+--- @return table buffers The loaded buffers whose results changed
+function M.place(locations, dirs, root)
+	local affected = {}
+	for bufnr in pairs(M.names) do
+		affected[bufnr] = true
+	end
+	M.clear()
+
+	local tests = {}
+
+	for _, location in ipairs(locations) do
+		local path = resolve(location, dirs, root)
+		if path then
+			tests[location.test] = true
+			M.pending[path] = M.pending[path] or {}
+			local row = location.line - 1
+			M.pending[path][row] = M.pending[path][row] or {}
+			add_name(M.pending[path][row], location.test)
+		end
+	end
+
+	for _, bufnr in ipairs(vim.api.nvim_list_bufs()) do
+		if vim.api.nvim_buf_is_loaded(bufnr) then
+			local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+			if M.pending[path] then
+				place_pending(bufnr)
+				affected[bufnr] = true
+			end
+		end
+	end
+
+	return vim.tbl_count(tests), vim.tbl_keys(affected)
+end
+
+--- Check if `go test -json` output reports on at least one test
+--- @param lines table Lines of `go test -json` output
+--- @return boolean ran True if a test event is among them
+--- @return table build The build output events, what broke when nothing ran
+local function test_events(lines)
+	local build = {}
+	for _, line in ipairs(lines) do
+		local ok, event = pcall(vim.json.decode, line)
+		if ok and type(event) == "table" then
+			if event.Test then
+				return true, build
+			end
+			if event.Action == "build-output" and event.Output then
+				table.insert(build, event.Output)
+			end
+		end
+	end
+	return false, build
+end
+
+--- Run `go test -json ./...` in the module root and load its output
+--- @param root string The module root
+--- @param on_done function Called with the lines of output once the job exits, or nil and the error
+--- when go can't run or nothing was tested (a build failure)
+function M.run(root, on_done)
+	-- vim.system raises when go isn't installed
+	local ok, err = pcall(vim.system, { "go", "test", "-json", "./..." }, { cwd = root, text = true }, function(result)
+		vim.schedule(function()
+			local lines = vim.split(result.stdout or "", "\n", { trimempty = true })
+			local ran, build = test_events(lines)
+			if result.code ~= 0 and not ran then
+				local output = vim.trim(table.concat(build) .. (result.stderr or ""))
+				on_done(nil, string.format("go test exited with %d%s", result.code, output ~= "" and ":\n" .. output or ""))
+				return
+			end
+			on_done(lines)
+		end)
+	end)
+	if not ok then
+		on_done(nil, tostring(err))
+	end
+end
+
+return M
@@ -0,0 +1,307 @@
+local M = {}
+
+local utils = require("no-go.utils")
+
+-- calls building an error from a string literal, and how the literal becomes the message
+-- wrap: the message is the literal followed by ": " and the wrapped error
+local constructors = {
+	["fmt.Errorf"] = {},
+	["errors.New"] = {},
+	["errors.Errorf"] = {},
+	["errors.Wrap"] = { wrap = true },
+	["errors.Wrapf"] = { wrap = true },
+}
+
+local constructor_query = [[
+(call_expression
+  function: (selector_expression
+    operand: (identifier) @package
+    field: (field_identifier) @function)
+  arguments: (argument_list
+    [(interpreted_string_literal) (raw_string_literal)] @format)) @call
+]]
+
+local escapes = { n = "\n", t = "\t", r = "\r", ['"'] = '"', ["\\"] = "\\" }
+
+--- Decode a Go string literal
+--- @param text string The literal, quotes included
+--- @return string The value
+local function unquote(text)
+	if text:sub(1, 1) == "`" then
+		return text:sub(2, -2)
+	end
+	return (text:sub(2, -2):gsub("\\(.)", function(char)
+		return escapes[char] or char
+	end))
+end
+
+--- Turn a format string into a Lua pattern, verbs (%v, %w, %s, %d...) match anything
+--- @param format string The format string
+--- @return string pattern The pattern, unanchored
+--- @return number literal The number of literal characters, how specific the format is
+--- @return string|nil head The pattern of what comes before the trailing verb, when the format ends with one
+local function to_pattern(format)
+	local parts = {}
+	local literal = 0
+	local head = nil
+	local i = 1
+	while i <= #format do
+		local char = format:sub(i, i)
+		if char == "%" then
+			local verb_end = format:find("[^%+%-# 0-9%.%*%[%]]", i + 1)
+			local verb = verb_end and format:sub(verb_end, verb_end)
+			if verb == "%" then
+				table.insert(parts, "%%")
+				literal = literal + 1
+				i = verb_end + 1
+			else
+				if not verb_end or verb_end == #format then
+					head = table.concat(parts)
+				end
+				table.insert(parts, ".-")
+				i = (verb_end or #format) + 1
+			end
+		else
+			table.insert(parts, vim.pesc(char))
+			literal = literal + 1
+			i = i + 1
+		end
+	end
+	return table.concat(parts), literal, head
+end
+
+--- Collect the names of the functions called in a node
+--- @param node TSNode The node to walk
+--- @param source number|string The buffer number or source string
+--- @param calls table Set of names, filled in
+local function collect_calls(node, source, calls)
+	for child in node:iter_children() do
+		if child:type() == "call_expression" then
+			local func = child:field("function")[1]
+			if func then
+				local name = vim.treesitter.get_node_text(func, source):match("([%w_]+)$")
+				if name then
+					calls[name] = true
+				end
+			end
+		end
+		collect_calls(child, source, calls)
+	end
+end
+
+--- Find the error block (if x != nil) a node lives in
+--- @param node TSNode The node
+--- @return TSNode|nil The if statement
+local function enclosing_error_block(node)
+	local current = node:parent()
+	while current do
+		local type = current:type()
+		if type == "if_statement" then
+			local condition = current:field("condition")[1]
+			local operator = condition and condition:type() == "binary_expression" and condition:child(1)
+			if operator and operator:type() == "!=" then
+				return current
+			end
+		elseif type == "function_declaration" or type == "method_declaration" then
+			return nil
+		end
+		current = current:parent()
+	end
+	return nil
+end
+
+--- Find the function or method declaration a node lives in, through function literals
+--- @param node TSNode The node
+--- @return TSNode|nil The declaration
+local function enclosing_declaration(node)
+	local current = node:parent()
+	while current do
+		if current:type() == "function_declaration" or current:type() == "method_declaration" then
+			return current
+		end
+		current = current:parent()
+	end
+	return nil
+end
+
+--- Collect the error format strings of a module
+--- @param root string The module root
+--- @return table List of sites: { path, row, col, format, pattern, literal, head, func_name,
+---   declaration_name, calls }
+--- the row and col are those of the error block around the call, or of the call outside of one
+function M.sites(root)
+	local query = vim.treesitter.query.parse("go", constructor_query)
+	local sites = {}
+
+	for _, path in ipairs(utils.module_files(root)) do
+		local tree_root, source = utils.parse_file(path)
+		if tree_root then
+			-- the calls made by each declaration, to tell which site calls which
+			local calls_cache = {}
+
+			for _, match in query:iter_matches(tree_root, source, 0, -1, { all = true }) do
+				local captures = {}
+				for id, nodes in pairs(match) do
+					captures[query.captures[id]] = nodes[1]
+				end
+
+				local name = vim.treesitter.get_node_text(captures.package, source)
+					.. "."
+					.. vim.treesitter.get_node_text(captures["function"], source)
+				local constructor = constructors[name]
+				if constructor then
+					local format = unquote(vim.treesitter.get_node_text(captures.format, source))
+					if constructor.wrap then
+						format = format .. ": %w"
+					end
+
+					local pattern, literal, head = to_pattern(format)
+					local block = enclosing_error_block(captures.call)
+					local anchor = block or captures.call
+					local row, col = anchor:start()
+					local _, func_name = utils.enclosing_function(captures.call, source)
+
+					local declaration = enclosing_declaration(captures.call)
+					local declaration_name = nil
+					local calls = {}
+					if declaration then
+						local name_node = declaration:field("name")[1]
+						declaration_name = name_node and vim.treesitter.get_node_text(name_node, source)
+
+						local key = declaration:id()
+						if not calls_cache[key] then
+							calls_cache[key] = {}
+							collect_calls(declaration, source, calls_cache[key])
+						end
+						calls = calls_cache[key]
+					end
+
+					table.insert(sites, {
+						path = path,
+						row = row,
+						col = col,
+						format = format,
+						pattern = pattern,
+						literal = literal,
+						head = head,
+						func_name = func_name,
+						declaration_name = declaration_name,
+						calls = calls,
+					})
+				end
+			end
+		end
+	end
+
+	return sites
+end
+
+--- Find the most probable chain of sites producing a message
+--- Each step either produces the rest of the message (errors.New("invalid address")) or a
+--- prefix and wraps the rest ("validate email: %w"). Steps are scored by their literal
+--- characters, and a step whose function is called by the previous step's function wins ties.
+--- What no site produces is left over, an error from outside the module.
+--- @param message string The message, as logged
+--- @param sites table The sites, from M.sites()
+--- @return table path List of { site, text }, outermost first
+--- @return string leftover The end of the message no site produced
+function M.match(message, sites)
+	local memo = {}
+
+	local function best(pos, previous)
+		-- the bonus depends on the calls of the previous declaration, shared by its sites
+		local key = pos .. ":" .. (previous and tostring(previous.calls) or "")
+		if memo[key] then
+			return memo[key]
+		end
+
+		local rest = message:sub(pos)
+		-- stopping here leaves the rest to an error from outside the module
+		local result = { score = 0, steps = {}, leftover = rest }
+
+		for _, site in ipairs(sites) do
+			local bonus = (previous and site.declaration_name and previous.calls[site.declaration_name]) and 1 or 0
+			local score = site.literal * 2 + bonus
+
+			if rest:match("^" .. site.pattern .. "$") and score > result.score then
+				result = { score = score, steps = { { site = site, text = rest } }, leftover = "" }
+			end
+
+			if site.head and site.literal > 0 then
+				local consumed = rest:match("^(" .. site.head .. ")")
+				if consumed and #consumed > 0 and #consumed < #rest then
+					local tail = best(pos + #consumed, site)
+					if score + tail.score > result.score then
+						local steps = { { site = site, text = consumed } }
+						vim.list_extend(steps, tail.steps)
+						result = { score = score + tail.score, steps = steps, leftover = tail.leftover }
+					end
+				end
+			end
+		end
+
+		memo[key] = result
+		return result
+	end
+
+	local result = best(1, nil)
+	return result.steps, result.leftover
+end
+
+--- Build the quickfix items of a trace: the path, then the other sites with the same formats
+--- @param path table The path, from M.match()
+--- @param sites table Every site
+--- @return table The quickfix items
+function M.quickfix_items(path, sites)
+	local items = {}
+	local on_path = {}
+
+	for i, step in ipairs(path) do
+		on_path[step.site] = true
+		table.insert(items, {
+			filename = step.site.path,
+			lnum = step.site.row + 1,
+			col = step.site.col + 1,
+			text = string.format("[%d/%d] %s  (%s)", i, #path, step.site.format, step.site.func_name or "top level"),
+		})
+	end
+
+	for i, step in ipairs(path) do
+		for _, site in ipairs(sites) do
+			if not on_path[site] and site.format == step.site.format then
+				table.insert(items, {
+					filename = site.path,
+					lnum = site.row + 1,
+					col = site.col + 1,
+					text = string.format("[%d/%d alt] %s  (%s)", i, #path, site.format, site.func_name or "top level"),
+				})
+			end
+		end
+	end
+
+	return items
+end
+
+--- Trace a message to the sites of the module of a buffer, into the quickfix list
+--- @param bufnr number The buffer number
+--- @param message string The message, as logged
+function M.run(bufnr, message)
+	local root = utils.module_root(bufnr)
+	local sites = M.sites(root)
+	local path, leftover = M.match(message, sites)
+
+	if #path == 0 then
+		vim.notify("no-go.nvim: No error format in " .. root .. " produces that message", vim.log.levels.WARN)
+		return
+	end
+
+	local title = "NoGoTrace: " .. message
+	if leftover ~= "" then
+		title = title .. "  (from outside the module: " .. leftover .. ")"
+	end
+
+	vim.fn.setqflist({}, " ", { title = title, items = M.quickfix_items(path, sites) })
+	vim.cmd("copen")
+end
+
+return M
@@ -0,0 +1,152 @@
+local M = {}
+
+-- reveal counts, kept in a local file only, path -> { [anchor] = count }
+M.path = vim.fn.stdpath("state") .. "/no-go/usage.json"
+
+-- the decoded file, loaded on first use
+M.counts = nil
+
+-- anchors of the blocks revealed by the last process_buffer run, bufnr -> { [anchor] = true }
+-- a reveal counts once, when the block opens, not on every cursor move inside it
+M.revealed = {}
+
+-- counts are written this long (ms) after the first change, and when neovim exits
+M.save_delay = 2000
+
+-- counts changed since the last write
+local dirty = false
+
+--- Load the counts from the state file
+--- @return table path -> { [anchor] = count }
+local function load()
+	if M.counts then
+		return M.counts
+	end
+
+	M.counts = {}
+	if vim.fn.filereadable(M.path) == 1 then
+		local ok, counts = pcall(function()
+			return vim.json.decode(table.concat(vim.fn.readfile(M.path), "\n"))
+		end)
+		if ok and type(counts) == "table" then
+			M.counts = counts
+		end
+	end
+	return M.counts
+end
+
+--- Write the counts to the state file
+local function save()
+	dirty = false
+	vim.fn.mkdir(vim.fs.dirname(M.path), "p")
+	local ok, err = pcall(vim.fn.writefile, { vim.json.encode(M.counts) }, M.path)
+	if not ok then
+		vim.notify("no-go.nvim: Can't save the reveal counts: " .. tostring(err), vim.log.levels.WARN)
+	end
+end
+
+--- Write the counts if they changed since the last write
+function M.flush()
+	if dirty then
+		save()
+	end
+end
+
+--- The file a buffer's counts are kept under
+--- @param bufnr number The buffer number
+--- @return string|nil The normalized path, nil for unnamed buffers
+local function file_key(bufnr)
+	local name = vim.api.nvim_buf_get_name(bufnr)
+	if name == "" then
+		return nil
+	end
+	return vim.fs.normalize(name)
+end
+
+--- Give each block its anchor: the function it's in, its kind and its ordinal among the blocks
+--- of that kind in the function, which survives edits elsewhere in the file
+--- @param blocks table The blocks, sorted by start row
+function M.anchor(blocks)
+	local ordinals = {}
+	for _, block in ipairs(blocks) do
+		local key = (block.func_name or "") .. "#" .. (block.kind or "")
+		ordinals[key] = (ordinals[key] or 0) + 1
+		block.anchor = key .. "#" .. ordinals[key]
+	end
+end
+
+--- How many times a block was revealed
+--- @param bufnr number The buffer number
+--- @param block table The block, with its anchor
+--- @return number The count
+function M.count(bufnr, block)
+	local file = file_key(bufnr)
+	local counts = file and load()[file]
+	return counts and block.anchor and counts[block.anchor] or 0
+end
+
+--- Check if a block is revealed often enough to stop hiding it
+--- @param bufnr number The buffer number
+--- @param block table The block
+--- @param config table The plugin configuration
+--- @return boolean True if adaptive collapsing should leave it open
+function M.is_frequent(bufnr, block, config)
+	return config.adaptive.enabled and M.count(bufnr, block) >= config.adaptive.threshold
+end
+
+--- Count the blocks that were just revealed by hand after they were collapsed
+--- Reveals by the cursor don't count, walking past a block isn't asking for it
+--- @param bufnr number The buffer number
+--- @param blocks table The blocks, after collapsing
+--- @param config table The plugin configuration
+function M.update(bufnr, blocks, config)
+	if not config.adaptive.enabled then
+		return
+	end
+
+	local file = file_key(bufnr)
+	if not file then
+		return
+	end
+
+	local previous = M.revealed[bufnr] or {}
+	local revealed = {}
+	local changed = false
+	for _, block in ipairs(blocks) do
+		if block.anchor and block.revealed_by == "hand" then
+			revealed[block.anchor] = true
+			if not previous[block.anchor] then
+				local counts = load()
+				counts[file] = counts[file] or {}
+				counts[file][block.anchor] = (counts[file][block.anchor] or 0) + 1
+				changed = true
+			end
+		end
+	end
+	M.revealed[bufnr] = revealed
+
+	-- a burst of reveals is one write
+	if changed and not dirty then
+		dirty = true
+		vim.defer_fn(M.flush, M.save_delay)
+	end
+end
+
+--- Forget the reveal counts
+--- @param bufnr number|nil Only forget those of this buffer's file, everything when nil
+function M.reset(bufnr)
+	local counts = load()
+	if bufnr then
+		local file = file_key(bufnr)
+		if file then
+			counts[file] = nil
+		end
+		M.revealed[bufnr] = nil
+	else
+		M.counts = {}
+		M.revealed = {}
+	end
+	save()
+end
+
+return M
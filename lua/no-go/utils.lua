@@ -21,6 +21,423 @@ function M.is_configured_identifier(node, bufnr, config)
 	return false
 end
 
+--- The statements of a block node (newer Go parsers wrap them in a statement_list)
+--- @param block_node TSNode The block node
+--- @return table List of named statement nodes, comments excluded
+function M.block_statements(block_node)
+	local container = block_node
+	for child in block_node:iter_children() do
+		if child:type() == "statement_list" then
+			container = child
+		end
+	end
+
+	local statements = {}
+	for child in container:iter_children() do
+		if child:named() and child:type() ~= "comment" then
+			table.insert(statements, child)
+		end
+	end
+	return statements
+end
+
+--- Check if an expression is a zero value literal (nil, 0, "", false)
+--- @param node TSNode The expression node
+--- @param source number|string The buffer number or source string
+--- @return boolean True for a zero value
+function M.is_zero_value(node, source)
+	local text = vim.treesitter.get_node_text(node, source)
+	local type = node:type()
+	if type == "nil" or type == "false" then
+		return true
+	end
+	if type == "int_literal" or type == "float_literal" then
+		return tonumber((text:gsub("_", ""))) == 0
+	end
+	if type == "interpreted_string_literal" or type == "raw_string_literal" then
+		return #text == 2
+	end
+	return false
+end
+
+--- The statement of a lazy initialisation block, when all it does is assign to the target
+--- @param block_node TSNode The block node
+--- @param target TSNode The tested expression
+--- @param source number|string The buffer number or source string
+--- @return TSNode|nil The assigned value
+function M.lazy_init_value(block_node, target, source)
+	local statements = M.block_statements(block_node)
+	if #statements ~= 1 or statements[1]:type() ~= "assignment_statement" then
+		return nil
+	end
+
+	local assignment = statements[1]
+	local operator = assignment:field("operator")[1]
+	local left = assignment:field("left")[1]
+	local right = assignment:field("right")[1]
+	if not operator or operator:type() ~= "=" or not left or not right then
+		return nil
+	end
+	if left:named_child_count() ~= 1 or right:named_child_count() ~= 1 then
+		return nil
+	end
+
+	local target_text = vim.treesitter.get_node_text(target, source)
+	if vim.treesitter.get_node_text(left:named_child(0), source) ~= target_text then
+		return nil
+	end
+	return right:named_child(0)
+end
+
+-- the comparison that holds when a condition doesn't, for assertions
+local negated_operators = { ["=="] = "!=", ["!="] = "==", ["<"] = ">=", [">="] = "<", [">"] = "<=", ["<="] = ">" }
+
+-- the types of the handle a test, benchmark or test helper reports with
+local testing_types = { ["*testing.T"] = true, ["*testing.B"] = true, ["*testing.F"] = true, ["testing.TB"] = true }
+
+--- The type of a function's parameter, as written without spaces
+--- @param func TSNode The function_declaration, method_declaration or func_literal node
+--- @param name string The parameter name
+--- @param source number|string The buffer number or source string
+--- @return string|nil The type, nil when the function has no such parameter
+local function parameter_type(func, name, source)
+	local params = func:field("parameters")[1]
+	if not params then
+		return nil
+	end
+	for param in params:iter_children() do
+		if param:type() == "parameter_declaration" then
+			for _, param_name in ipairs(param:field("name")) do
+				if vim.treesitter.get_node_text(param_name, source) == name then
+					local param_type = param:field("type")[1]
+					return param_type and (vim.treesitter.get_node_text(param_type, source):gsub("%s+", "")) or ""
+				end
+			end
+		end
+	end
+	return nil
+end
+
+--- Check if an identifier is the *testing.T, *testing.B, *testing.F or testing.TB parameter of a function around it
+--- The innermost function with a parameter of that name decides, so func(t *testing.T) of a t.Run counts
+--- and a t of another type shadowing it doesn't
+--- @param node TSNode The identifier node
+--- @param source number|string The buffer number or source string
+--- @return boolean True if it's the testing handle
+local function is_testing_handle(node, source)
+	local name = vim.treesitter.get_node_text(node, source)
+	local current = node:parent()
+	while current do
+		local type = current:type()
+		if type == "function_declaration" or type == "method_declaration" or type == "func_literal" then
+			local param_type = parameter_type(current, name, source)
+			if param_type then
+				return testing_types[param_type] == true
+			end
+		end
+		current = current:parent()
+	end
+	return false
+end
+
+--- The reporting call of a test assertion block, when the block is nothing but t.Error* or t.Fatal*
+--- on the testing handle of the test
+--- @param block_node TSNode The block node
+--- @param source number|string The buffer number or source string
+--- @return string|nil "error" or "fatal"
+function M.assertion_call(block_node, source)
+	local statements = M.block_statements(block_node)
+	if #statements ~= 1 or statements[1]:type() ~= "expression_statement" then
+		return nil
+	end
+
+	local call = statements[1]:named_child(0)
+	local func = call and call:type() == "call_expression" and call:field("function")[1]
+	if not func or func:type() ~= "selector_expression" then
+		return nil
+	end
+	local operand = func:field("operand")[1]
+	if not operand or operand:type() ~= "identifier" or not is_testing_handle(operand, source) then
+		return nil
+	end
+
+	local method = vim.treesitter.get_node_text(func:field("field")[1], source)
+	if method == "Error" or method == "Errorf" then
+		return "error"
+	elseif method == "Fatal" or method == "Fatalf" then
+		return "fatal"
+	end
+	return nil
+end
+
+--- What an assertion checks: the negation of the condition that reports the failure
+--- got != want becomes got == want, !reflect.DeepEqual(a, b) becomes a == b
+--- @param node TSNode The condition node
+--- @param source number|string The buffer number or source string
+--- @return string The text
+function M.negate_condition(node, source)
+	local text = function(n)
+		return (vim.treesitter.get_node_text(n, source):gsub("%s+", " "))
+	end
+
+	local type = node:type()
+	if type == "parenthesized_expression" and node:named_child(0) then
+		return M.negate_condition(node:named_child(0), source)
+	end
+
+	if type == "binary_expression" then
+		local operator = node:field("operator")[1]
+		local negated = operator and negated_operators[operator:type()]
+		if negated then
+			return text(node:field("left")[1]) .. " " .. negated .. " " .. text(node:field("right")[1])
+		end
+	end
+
+	if type == "unary_expression" and node:child(0) and node:child(0):type() == "!" then
+		local operand = node:field("operand")[1]
+		-- the Equal helpers (reflect.DeepEqual, bytes.Equal, cmp.Equal...) read as a comparison
+		if operand:type() == "call_expression" then
+			local name = text(operand:field("function")[1])
+			local args = {}
+			for arg in operand:field("arguments")[1]:iter_children() do
+				if arg:named() and arg:type() ~= "comment" then
+					table.insert(args, arg)
+				end
+			end
+			if name:match("Equal$") and #args == 2 then
+				return text(args[1]) .. " == " .. text(args[2])
+			end
+		end
+		return text(operand)
+	end
+
+	if type == "identifier" or type == "call_expression" or type == "selector_expression" then
+		return "!" .. text(node)
+	end
+	return "!(" .. text(node) .. ")"
+end
+
+--- A short form of an expression for markers: calls become f(...), composite literals T{...}
+--- @param node TSNode The expression node
+--- @param source number|string The buffer number or source string
+--- @return string The text
+function M.short_expression(node, source)
+	local type = node:type()
+	if type == "call_expression" then
+		return vim.treesitter.get_node_text(node:field("function")[1], source) .. "(...)"
+	end
+	if type == "composite_literal" then
+		return vim.treesitter.get_node_text(node:field("type")[1], source) .. "{...}"
+	end
+	if type == "unary_expression" and node:named_child(0) and node:named_child(0):type() == "composite_literal" then
+		return vim.treesitter.get_node_text(node, source):sub(1, 1) .. M.short_expression(node:named_child(0), source)
+	end
+
+	local text = vim.treesitter.get_node_text(node, source):gsub("%s+", " ")
+	if vim.fn.strchars(text) > 30 then
+		text = vim.fn.strcharpart(text, 0, 29) .. "…"
+	end
+	return text
+end
+
+--- Name of the terminator a statement is, if it is one
+--- @param statement TSNode The statement node
+--- @param source number|string The buffer number or source string
+--- @return string|nil "return", "continue", "break", "goto" or the called function (e.g. "log.Fatal")
+function M.statement_terminator(statement, source)
+	local type = statement:type()
+	if type == "return_statement" then
+		return "return"
+	elseif type == "continue_statement" then
+		return "continue"
+	elseif type == "break_statement" then
+		return "break"
+	elseif type == "goto_statement" then
+		return "goto"
+	elseif type == "expression_statement" then
+		local call = statement:named_child(0)
+		if call and call:type() == "call_expression" then
+			local func = call:field("function")[1]
+			if func then
+				return vim.treesitter.get_node_text(func, source)
+			end
+		end
+	end
+	return nil
+end
+
+--- Check if a terminator name is in the configured list
+--- entries ending in "*" match by prefix, so "log.Fatal*" covers log.Fatalf and log.Fatalln
+--- @param name string The terminator name
+--- @param terminators table The configured terminators
+--- @return boolean True if the name is a configured terminator
+function M.is_terminator(name, terminators)
+	for _, terminator in ipairs(terminators) do
+		if terminator == name then
+			return true
+		end
+		if terminator:sub(-1) == "*" and vim.startswith(name, terminator:sub(1, -2)) then
+			return true
+		end
+	end
+	return false
+end
+
+--- Find what ends a block: a return among its statements, or a configured terminator as its
+--- last statement
+--- @param block_node TSNode The block node
+--- @param source number|string The buffer number or source string
+--- @param terminators table The configured terminators
+--- @return string|nil terminator The terminator name, or nil if the block doesn't terminate
+--- @return string|nil return_content The last identifier returned (e.g. "err"), for returns
+function M.find_terminator(block_node, source, terminators)
+	local statements = M.block_statements(block_node)
+
+	if vim.tbl_contains(terminators, "return") then
+		for _, statement in ipairs(statements) do
+			if statement:type() == "return_statement" then
+				local return_content = nil
+				local values = statement:named_child(0)
+				if values and values:type() == "expression_list" then
+					for value in values:iter_children() do
+						if value:type() == "identifier" then
+							return_content = vim.treesitter.get_node_text(value, source)
+						end
+					end
+				end
+				return "return", return_content
+			end
+		end
+	end
+
+	local last = statements[#statements]
+	if not last then
+		return nil, nil
+	end
+
+	local name = M.statement_terminator(last, source)
+	if name and name ~= "return" and M.is_terminator(name, terminators) then
+		return name, nil
+	end
+	return nil, nil
+end
+
+--- Find the function, method or function literal a node lives in
+--- @param node TSNode The node to start from
+--- @param bufnr number The buffer number
+--- @return TSNode|nil func_node The enclosing function node, or nil at the top level
+--- @return string|nil name The function name ("func" for literals)
+function M.enclosing_function(node, bufnr)
+	local current = node:parent()
+	while current do
+		local type = current:type()
+		if type == "function_declaration" or type == "method_declaration" then
+			local name_node = current:field("name")[1]
+			return current, name_node and vim.treesitter.get_node_text(name_node, bufnr) or "func"
+		elseif type == "func_literal" then
+			return current, "func"
+		end
+		current = current:parent()
+	end
+	return nil, nil
+end
+
+--- Find the module root (the directory with go.mod) of a buffer
+--- @param bufnr number The buffer number
+--- @return string The module root, or the current directory outside of a module
+function M.module_root(bufnr)
+	return vim.fs.normalize(vim.fs.root(bufnr, "go.mod") or vim.fn.getcwd())
+end
+
+--- List the Go files of a module, skipping vendor, testdata and hidden directories
+--- @param root string The module root
+--- @return table List of absolute paths
+function M.module_files(root)
+	local files = {}
+	for name, type in
+		vim.fs.dir(root, {
+			depth = 32,
+			skip = function(dir)
+				local base = vim.fs.basename(dir)
+				return base ~= "vendor" and base ~= "testdata" and not vim.startswith(base, ".")
+			end,
+		})
+	do
+		if type == "file" and name:match("%.go$") then
+			table.insert(files, root .. "/" .. name)
+		end
+	end
+	table.sort(files)
+	return files
+end
+
+--- Parse a Go file, from its buffer when it is loaded (unsaved changes count) or from disk
+--- @param path string The absolute path
+--- @return TSNode|nil root The root node, or nil if the file can't be read or parsed
+--- @return number|string|nil source The buffer number or the file content, for get_node_text
+function M.parse_file(path)
+	local bufnr = vim.fn.bufnr(path)
+	local ok, parser, source
+	if bufnr > 0 and vim.api.nvim_buf_is_loaded(bufnr) then
+		source = bufnr
+		ok, parser = pcall(vim.treesitter.get_parser, bufnr, "go")
+	else
+		local read_ok, lines = pcall(vim.fn.readfile, path)
+		if not read_ok then
+			return nil, nil
+		end
+		source = table.concat(lines, "\n")
+		ok, parser = pcall(vim.treesitter.get_string_parser, source, "go")
+	end
+
+	if not ok or not parser then
+		return nil, nil
+	end
+
+	local tree = parser:parse()[1]
+	if not tree then
+		return nil, nil
+	end
+	return tree:root(), source
+end
+
+--- Check if a buffer should be left alone: shown in a codediff diff window, or the skip predicate says so
+--- @param bufnr number The buffer number
+--- @param config table The plugin configuration
+--- @return string|nil Why the buffer is skipped, or nil
+function M.skip_reason(bufnr, config)
+	for _, win in ipairs(vim.fn.win_findbuf(bufnr)) do
+		if vim.w[win].codediff_restore then
+			return "shown in a codediff window"
+		end
+	end
+
+	if type(config.skip) == "function" then
+		local ok, skip = pcall(config.skip, bufnr)
+		if not ok then
+			return "skip predicate failed: " .. tostring(skip)
+		end
+		if skip then
+			return type(skip) == "string" and skip or "skip predicate"
+		end
+	end
+	return nil
+end
+
+--- Find the exclude pattern matching the path of a buffer
+--- @param bufnr number The buffer number
+--- @param config table The plugin configuration
+--- @return string|nil The matching Lua pattern, or nil
+function M.excluded_by(bufnr, config)
+	local path = vim.fs.normalize(vim.api.nvim_buf_get_name(bufnr))
+	for _, pattern in ipairs(config.exclude or {}) do
+		if path:find(pattern) then
+			return pattern
+		end
+	end
+	return nil
+end
+
 --- Find the position of the opening brace on the if line
 --- @param bufnr number The buffer number
 --- @param if_start_row number The row number of the if statement
@@ -82,6 +499,123 @@ function M.build_virtual_text(content, config)
 	return result
 end
 
+--- Build the rendering levels of the virtual text, from the full text down to the glyph
+--- Levels: full (prefix + content + separator + glyph + suffix), content + glyph, glyph only
+--- @param content string|nil The identifier from the return statement (e.g., "err"), or nil
+--- @param config table The plugin configuration
+--- @return table List of strings, widest first
+function M.build_virtual_text_levels(content, config)
+	local glyph = config.virtual_text.return_character or "󱞿 "
+	local levels = { M.build_virtual_text(content, config) }
+
+	if content and content ~= "" then
+		table.insert(levels, content .. (config.virtual_text.content_separator or " ") .. glyph)
+	end
+	table.insert(levels, glyph)
+
+	-- drop levels that are not narrower than the one before (e.g. an empty prefix and suffix)
+	local narrowing = { levels[1] }
+	for i = 2, #levels do
+		if vim.fn.strdisplaywidth(levels[i]) < vim.fn.strdisplaywidth(narrowing[#narrowing]) then
+			table.insert(narrowing, levels[i])
+		end
+	end
+	return narrowing
+end
+
+--- Display columns left for the marker of a block in a window, after the text before it
+--- With wrap that is what's left of the screen line the marker starts on,
+--- without wrap it accounts for the horizontal scroll
+--- @param win number The window id
+--- @param bufnr number The buffer number
+--- @param block table The block (needs start_row and col)
+--- @return number The number of display columns
+function M.marker_space(win, bufnr, block)
+	local info = vim.fn.getwininfo(win)[1]
+	local width = info.width - info.textoff
+	local line = vim.api.nvim_buf_get_lines(bufnr, block.start_row, block.start_row + 1, false)[1] or ""
+
+	local view = vim.api.nvim_win_call(win, function()
+		return {
+			before = vim.fn.strdisplaywidth(line:sub(1, block.col)),
+			leftcol = vim.fn.winsaveview().leftcol,
+		}
+	end)
+
+	if vim.wo[win].wrap then
+		return width - view.before % width
+	end
+
+	-- scrolled past the marker, it is off screen whatever its width
+	if view.leftcol > view.before then
+		return width
+	end
+	return width - (view.before - view.leftcol)
+end
+
+--- Display column (1-indexed, counted from the start of the buffer line) under the mouse
+--- Accounts for the sign/number column, horizontal scroll and wrapped lines
+--- @param pos table The result of vim.fn.getmousepos()
+--- @return number|nil The virtual column, or nil if the mouse is not over buffer text
+function M.mouse_vcol(pos)
+	if pos.winid == 0 or pos.line == 0 then
+		return nil
+	end
+
+	local info = vim.fn.getwininfo(pos.winid)[1]
+	if not info then
+		return nil
+	end
+
+	local col = pos.wincol - info.textoff
+	if col < 1 then
+		return nil
+	end
+
+	-- screen row where the buffer line starts, later screen rows are wrapped parts of it
+	local line_start = vim.fn.screenpos(pos.winid, pos.line, 1)
+	if line_start.row == 0 then
+		return nil
+	end
+
+	local leftcol = vim.api.nvim_win_call(pos.winid, function()
+		return vim.fn.winsaveview().leftcol
+	end)
+
+	local width = info.width - info.textoff
+	return (pos.screenrow - line_start.row) * width + col + leftcol
+end
+
+--- Display columns covered by the inline marker of a block in a window
+--- @param win number The window id
+--- @param bufnr number The buffer number
+--- @param block table The block (needs start_row, col and rendered or text)
+--- @return number first The first virtual column of the marker (1-indexed)
+--- @return number last The last virtual column of the marker (1-indexed)
+function M.marker_vcols(win, bufnr, block)
+	local line = vim.api.nvim_buf_get_lines(bufnr, block.start_row, block.start_row + 1, false)[1] or ""
+
+	-- strdisplaywidth uses the tabstop of the current window, so ask the right one
+	local widths = vim.api.nvim_win_call(win, function()
+		return { vim.fn.strdisplaywidth(line:sub(1, block.col)), vim.fn.strdisplaywidth(block.rendered or block.text) }
+	end)
+
+	return widths[1] + 1, widths[1] + widths[2]
+end
+
+--- Window row a buffer row is drawn on, after concealed lines, folds and wrapped lines above it
+--- @param win number The window id
+--- @param row number The buffer row (0-indexed)
+--- @return number|nil The window row (0-indexed), or nil if the row is not on screen
+function M.screen_row(win, row)
+	local pos = vim.fn.screenpos(win, row + 1, 1)
+	if pos.row == 0 then
+		return nil
+	end
+	local info = vim.fn.getwininfo(win)[1]
+	return pos.row - info.winrow - (info.winbar or 0)
+end
+
 --- Check if a line is concealed by an extmark
 --- @param bufnr number The buffer number
 --- @param row number The row number to check (0-indexed)
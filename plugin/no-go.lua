@@ -28,6 +28,91 @@ vim.api.nvim_create_user_command("NoGoBufToggle", function()
 	require("no-go").toggle_buffer()
 end, { desc = "Toggle no-go for current buffer only" })
 
+vim.api.nvim_create_user_command("NoGoBufReset", function()
+	require("no-go").reset_buffer()
+end, { desc = "Make the current buffer follow the global no-go state again" })
+
+vim.api.nvim_create_user_command("NoGoCategoryToggle", function(args)
+	require("no-go").toggle_category(args.args)
+end, {
+	nargs = 1,
+	complete = function()
+		return require("no-go.matchers").categories()
+	end,
+	desc = "Toggle the blocks of a matcher category in every buffer",
+})
+
+vim.api.nvim_create_user_command("NoGoStatus", function()
+	require("no-go").status()
+end, { desc = "Show whether no-go is on for the current buffer, and why" })
+
 vim.api.nvim_create_user_command("NoGoRefresh", function()
 	require("no-go").refresh()
 end, { desc = "Refresh no-go error collapsing for current buffer" })
+
+vim.api.nvim_create_user_command("NoGoBlockToggle", function()
+	require("no-go").toggle_block()
+end, { desc = "Reveal or collapse the block under the cursor" })
+
+vim.api.nvim_create_user_command("NoGoCycle", function()
+	require("no-go").cycle()
+end, { desc = "Step the block under the cursor through hidden, summary, dimmed and expanded" })
+
+vim.api.nvim_create_user_command("NoGoPeek", function()
+	require("no-go").peek()
+end, { desc = "Show the hidden lines of the block under the cursor in a float" })
+
+vim.api.nvim_create_user_command("NoGoTestResults", function(args)
+	require("no-go").test_results(args.args, args.bang)
+end, {
+	nargs = "?",
+	bang = true,
+	complete = "file",
+	desc = "Reveal blocks hit by failing tests from go test -json output (runs go test without a file, ! clears)",
+})
+
+vim.api.nvim_create_user_command("NoGoDuplicates", function(args)
+	require("no-go").duplicates(args.bang)
+end, { bang = true, desc = "List the groups of same-shaped error blocks in the quickfix list (! the whole module)" })
+
+vim.api.nvim_create_user_command("NoGoLearn", function(args)
+	require("no-go").learn(args.bang)
+end, { bang = true, desc = "Propose a configuration from the module's error handling (! writes it)" })
+
+vim.api.nvim_create_user_command("NoGoMargin", function()
+	require("no-go").margin()
+end, { desc = "Show the hidden lines of the collapsed blocks in a window beside the current one (toggle)" })
+
+vim.api.nvim_create_user_command("NoGoReview", function(args)
+	require("no-go").review(args.fargs, args.bang)
+end, {
+	nargs = "*",
+	bang = true,
+	complete = function(_, line)
+		if #vim.split(vim.trim(line), "%s+") > 2 then
+			return {}
+		end
+		return { "ok", "change", "skip", "summary", "stop", "reset" }
+	end,
+	desc = "Review the error blocks of this file (! the package) one by one: ok, change {note}, skip, summary, stop",
+})
+
+vim.api.nvim_create_user_command("NoGoResetLearning", function(args)
+	require("no-go").reset_learning(args.bang)
+end, { bang = true, desc = "Forget how often the blocks of this file were revealed (! every file)" })
+
+vim.api.nvim_create_user_command("NoGoUnchecked", function(args)
+	require("no-go").unchecked(args.bang)
+end, { bang = true, desc = "Mark calls whose error result is never checked (! clears the marks)" })
+
+vim.api.nvim_create_user_command("NoGoErrStyle", function(args)
+	require("no-go").errstyle(args.bang)
+end, { bang = true, desc = "Mark error strings that break the Go conventions (! clears the marks)" })
+
+vim.api.nvim_create_user_command("NoGoTrace", function(args)
+	require("no-go").trace(args.args)
+end, { nargs = "+", desc = "Trace an error message to the error blocks that build it (quickfix)" })
+
+vim.api.nvim_create_user_command("NoGoStats", function()
+	require("no-go").stats()
+end, { desc = "Show the error handling dashboard of the current buffer" })
@@ -0,0 +1,83 @@
+-- Stand-in for a debug adapter: places signs and sends stops the way nvim-dap does,
+-- then checks that no-go marks and reveals the blocks hiding them.
+--
+-- Run from the repository root (the Go Treesitter parser must be on the runtimepath):
+--   nvim --headless -u NONE -l tests/debug_stand_in.lua
+
+vim.opt.runtimepath:prepend(vim.fn.getcwd())
+vim.cmd("filetype on")
+
+local no_go = require("no-go")
+local config = require("no-go.config")
+local fold = require("no-go.fold")
+
+local function fail(msg)
+	io.stderr:write("FAIL: " .. msg .. "\n")
+	os.exit(1)
+end
+
+local function block_at(bufnr, row)
+	for _, block in ipairs(fold.blocks[bufnr] or {}) do
+		if block.start_row == row then
+			return block
+		end
+	end
+	fail("no block on row " .. row)
+end
+
+local function marker_has_indicator(bufnr, row)
+	local block = block_at(bufnr, row)
+	if not block.mark_id then
+		return false
+	end
+	local mark = vim.api.nvim_buf_get_extmark_by_id(bufnr, fold.namespace, block.mark_id, { details = true })
+	local chunks = mark[3] and mark[3].virt_text or {}
+	return chunks[2] ~= nil and chunks[2][1] == config.options.debugger.indicator
+end
+
+no_go.setup({ debugger = { enabled = true, poll_interval = 50 } })
+vim.cmd("edit test_example.go")
+local bufnr = vim.api.nvim_get_current_buf()
+fold.process_buffer(bufnr, config.options)
+
+local errors = vim.tbl_filter(function(block)
+	return block.kind == "error"
+end, fold.blocks[bufnr] or {})
+if #errors < 2 then
+	fail("expected at least two error blocks in test_example.go, got " .. #errors)
+end
+local first, second = errors[1].start_row, errors[2].start_row
+
+-- breakpoint on a hidden line: the collapsed line gets the indicator once the poll notices
+vim.fn.sign_define("DapBreakpoint", { text = "B" })
+vim.fn.sign_place(0, "dap_breakpoints", "DapBreakpoint", bufnr, { lnum = first + 2 })
+if not vim.wait(1000, function()
+	return marker_has_indicator(bufnr, first)
+end) then
+	fail("breakpoint on a hidden line did not mark the block")
+end
+if not block_at(bufnr, first).collapsed then
+	fail("a breakpoint should mark the block, not reveal it")
+end
+
+-- the debugger stops inside the second block: it is revealed
+vim.api.nvim_exec_autocmds("User", { pattern = "NoGoDebugStop", data = { buf = bufnr, line = second + 2 } })
+if block_at(bufnr, second).collapsed then
+	fail("stop location inside a block did not reveal it")
+end
+
+-- the session continues: the block collapses again
+vim.api.nvim_exec_autocmds("User", { pattern = "NoGoDebugContinue" })
+if not block_at(bufnr, second).collapsed then
+	fail("block stayed revealed after the session continued")
+end
+
+-- breakpoint removed: the indicator goes away
+vim.fn.sign_unplace("dap_breakpoints", { buffer = bufnr })
+if not vim.wait(1000, function()
+	return not marker_has_indicator(bufnr, first)
+end) then
+	fail("indicator stayed after the breakpoint was removed")
+end
+
+print("ok")
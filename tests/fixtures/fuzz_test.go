@@ -0,0 +1,30 @@
+package fixtures
+
+import (
+	"strconv"
+	"testing"
+)
+
+func FuzzAtoi(f *testing.F) {
+	f.Add("42")
+	f.Add("-7")
+
+	if _, err := strconv.Atoi("0"); err != nil {
+		f.Fatalf("seed: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return
+		}
+
+		got, err := strconv.Atoi(strconv.Itoa(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != n {
+			t.Errorf("Atoi(Itoa(%d)) = %d", n, got)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+type Request struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func decode(r *http.Request) (Request, error) {
+	var req Request
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return req, fmt.Errorf("decode: %w", err)
+	}
+	return req, nil
+}
+
+func validate(req Request) error {
+	if req.Email == "" {
+		return errors.New("missing email")
+	}
+	return nil
+}
+
+func Submit(w http.ResponseWriter, r *http.Request) {
+	req, err := decode(r)
+	if err != nil {
+		http.Error(w, "bad request {", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// a brace in a comment }
+	f, err := os.Create(req.Name)
+	if err != nil { return }
+	defer f.Close()
+
+	_, err = f.WriteString("}")
+	if err != nil {
+		log.Fatalf("write: %v", err)
+	}
+}
+
+func mustOpen(path string) *os.File {
+	f, err := os.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
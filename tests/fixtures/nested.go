@@ -0,0 +1,52 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+)
+
+type Store interface {
+	Get(ctx context.Context, id string) (string, error)
+	Put(ctx context.Context, id, value string) error
+}
+
+func Copy(ctx context.Context, s Store, from, to string) (err error) {
+	value, err := s.Get(ctx, from)
+	if err != nil {
+		if err == context.Canceled {
+			return err
+		}
+		err = s.Put(ctx, to, "")
+		if err != nil {
+			return fmt.Errorf("reset %s: %w", to, err)
+		}
+		return fmt.Errorf("get %s: %w", from, err)
+	}
+
+	run := func() error {
+		err := s.Put(ctx, to, value)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if err = run(); err != nil {
+		return err
+	} else if value == "" {
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		err = s.Put(ctx, fmt.Sprint(to, i), value)
+		if err != nil {
+			continue
+		}
+	}
+
+	switch err := s.Put(ctx, to, value); {
+	case err != nil:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package fixtures
+
+import "os"
+
+func Load(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func Save(path string, data []byte) error {
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package fixtures
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+func Port() int {
+	raw := os.Getenv("PORT")
+	port, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatal("bad port")
+	}
+
+	e := check(port)
+	if e != nil {
+		os.Exit(1)
+	}
+
+	if err := check(port); err != nil {
+		panic(err)
+	}
+
+	if err := check(port); err != nil { panic(err) }
+
+	return port
+}
+
+func check(port int) error {
+	if port <= 0 {
+		return strconv.ErrRange
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+-- Rendering tests: starts an embedded Neovim, attaches to it as a UI (ext_linegrid) and checks
+-- the grid it draws, what is actually on screen rather than the extmarks behind it.
+--
+-- Run from the repository root (the Go Treesitter parser must be on the runtimepath):
+--   nvim --headless -u NONE -l tests/screen.lua
+-- A failing check prints the last screen it saw.
+
+vim.opt.runtimepath:prepend(vim.fn.getcwd())
+
+local uv = vim.uv
+
+local fixture = "tests/fixtures/screen.go"
+local width, height = 80, 24
+
+-- the embedded Neovim, set by start()
+local child = nil
+
+local function fail(name, msg)
+	io.stderr:write("FAIL (" .. name .. "): " .. msg .. "\n")
+	if child then
+		io.stderr:write("screen:\n")
+		for i, row in ipairs(child.screen) do
+			io.stderr:write(string.format("%2d |%s|\n", i, row))
+		end
+		child.handle:kill("sigterm")
+	end
+	os.exit(1)
+end
+
+local function is_nil(value)
+	return value == nil or value == vim.NIL or value == vim.mpack.NIL
+end
+
+--- Build the text of part of a grid row
+--- @param cells table The cells of the row, one string each ("" after a wide character)
+--- @param first number First column (1-indexed)
+--- @param last number Last column
+--- @return string The text
+local function row_text(cells, first, last)
+	return table.concat(cells, "", first, last)
+end
+
+-- the ext_linegrid events this UI draws, everything else is ignored
+local handlers = {
+	grid_resize = function(grid, _, w, h)
+		grid.width, grid.height = w, h
+		grid.rows = {}
+		for row = 1, h do
+			grid.rows[row] = {}
+			for col = 1, w do
+				grid.rows[row][col] = " "
+			end
+		end
+	end,
+	grid_clear = function(grid)
+		for _, cells in ipairs(grid.rows) do
+			for col = 1, #cells do
+				cells[col] = " "
+			end
+		end
+	end,
+	grid_line = function(grid, _, row, col, cells)
+		local line = grid.rows[row + 1]
+		for _, cell in ipairs(cells) do
+			-- text, then the highlight and repeat count when they change
+			for _ = 1, cell[3] or 1 do
+				line[col + 1] = cell[1]
+				col = col + 1
+			end
+		end
+	end,
+	grid_scroll = function(grid, _, top, bot, left, right, rows)
+		local first, last, step = top, bot - rows - 1, 1
+		if rows < 0 then
+			first, last, step = bot - 1, top - rows, -1
+		end
+		for row = first, last, step do
+			for col = left, right - 1 do
+				grid.rows[row + 1][col + 1] = grid.rows[row + rows + 1][col + 1]
+			end
+		end
+	end,
+	grid_cursor_goto = function(grid, _, row, col)
+		grid.cursor = { row = row + 1, col = col + 1 }
+	end,
+	flush = function(grid)
+		grid.screen = {}
+		grid.cells = {}
+		for row, cells in ipairs(grid.rows) do
+			grid.screen[row] = row_text(cells, 1, #cells)
+			grid.cells[row] = { unpack(cells) }
+		end
+		grid.flushes = grid.flushes + 1
+	end,
+}
+
+--- Apply a redraw notification: batches of { event, args, args... }
+local function redraw(batches)
+	for _, batch in ipairs(batches) do
+		local handler = handlers[batch[1]]
+		if handler then
+			for i = 2, #batch do
+				handler(child, unpack(batch[i]))
+			end
+		end
+	end
+end
+
+--- Send a request to the embedded Neovim and wait for its response
+--- @param method string The API function
+--- @param ... any Its arguments
+--- @return any The result
+local function request(method, ...)
+	local args = { ... }
+	local done, err, result = false, nil, nil
+	local header = child.session:request(function(response_err, response_result)
+		done, err, result = true, response_err, response_result
+	end)
+	child.stdin:write(header .. child.pack(method) .. child.pack(args))
+
+	if not vim.wait(5000, function()
+		return done
+	end, 5) then
+		fail(child.test, method .. " timed out")
+	end
+	if not is_nil(err) then
+		fail(child.test, method .. ": " .. vim.inspect(err))
+	end
+	return result
+end
+
+--- Run Lua in the embedded Neovim
+--- @param code string The chunk, gets its arguments as ...
+--- @param ... any The arguments
+--- @return any What the chunk returns
+local function exec_lua(code, ...)
+	return request("nvim_exec_lua", code, { ... })
+end
+
+--- Start an embedded Neovim set up with no-go and the fixture loaded
+--- @param test string The name of the test, for failures
+--- @param opts table The plugin options
+local function start(test, opts)
+	local stdin, stdout = uv.new_pipe(false), uv.new_pipe(false)
+	child = {
+		test = test,
+		stdin = stdin,
+		stdout = stdout,
+		rows = {},
+		cells = {},
+		screen = {},
+		cursor = { row = 1, col = 1 },
+		flushes = 0,
+		pack = vim.mpack.Packer(),
+		session = vim.mpack.Session({ unpack = vim.mpack.Unpacker() }),
+	}
+
+	child.handle = uv.spawn(vim.v.progpath, {
+		args = { "--embed", "-u", "NONE", "-i", "NONE", "-n" },
+		stdio = { stdin, stdout, nil },
+		cwd = vim.fn.getcwd(),
+	}, function() end)
+	if not child.handle then
+		fail(test, "could not start " .. vim.v.progpath)
+	end
+
+	stdout:read_start(function(err, data)
+		if err or not data then
+			return
+		end
+		local pos = 1
+		while pos <= #data do
+			local type, id_or_cb, method_or_err, args_or_result
+			pos, type, id_or_cb, method_or_err, args_or_result = child.session:receive(data, pos)
+			if type == "notification" and method_or_err == "redraw" then
+				redraw(args_or_result)
+			elseif type == "response" then
+				id_or_cb(method_or_err, args_or_result)
+			end
+		end
+	end)
+
+	request("nvim_ui_attach", width, height, { ext_linegrid = true })
+	request("nvim_set_option_value", "runtimepath", vim.o.runtimepath, {})
+	exec_lua(
+		[[
+		vim.cmd("filetype on")
+		require("no-go").setup(...)
+		vim.cmd("edit " .. select(2, ...))
+		]],
+		opts,
+		fixture
+	)
+end
+
+local function stop()
+	child.stdout:read_stop()
+	child.handle:kill("sigterm")
+	child.handle:close()
+	child.stdin:close()
+	child.stdout:close()
+	child = nil
+end
+
+--- Wait until the screen satisfies a check
+--- @param description string What is expected, for failures
+--- @param check function Gets the screen rows, returns true once satisfied
+local function expect(description, check)
+	-- the plugin debounces cursor movements, give it a moment before the first look
+	vim.wait(50)
+	if
+		not vim.wait(2000, function()
+			return child.flushes > 0 and check(child.screen)
+		end, 10)
+	then
+		fail(child.test, "expected " .. description)
+	end
+end
+
+--- Find the first row holding a text
+--- @param screen table The rows
+--- @param text string The text
+--- @return number|nil The row (1-indexed)
+local function find_row(screen, text)
+	for row, line in ipairs(screen) do
+		if line:find(text, 1, true) then
+			return row
+		end
+	end
+	return nil
+end
+
+--- Where a window is on the screen
+--- @param win number The window handle
+--- @return table { row, col, width, height }, 0-indexed
+local function window_position(win)
+	return exec_lua(
+		[[
+		local win = ...
+		local row, col = unpack(vim.api.nvim_win_get_position(win))
+		return { row, col, vim.api.nvim_win_get_width(win), vim.api.nvim_win_get_height(win) }
+		]],
+		win
+	)
+end
+
+--- The rows a window drew in the last screen
+--- @param position table The window position, from window_position()
+--- @return table The rows of the window, clipped to its columns
+local function window_screen(position)
+	local row, col, w, h = unpack(position)
+	local rows = {}
+	for i = row + 1, row + h do
+		table.insert(rows, row_text(child.cells[i], col + 1, col + w))
+	end
+	return rows
+end
+
+--- The Load block is collapsed: its body is gone, the brace hidden and the marker in its place
+local function load_collapsed(screen)
+	local if_row = find_row(screen, "if err != nil")
+	return if_row ~= nil
+		and not find_row(screen, "return nil, err")
+		and not screen[if_row]:find("{", 1, true)
+		and screen[if_row]:find("if err != nil : err", 1, true) ~= nil
+		and screen[if_row + 1]:find("return data, nil", 1, true) ~= nil
+end
+
+--- The Load block is revealed: the whole if statement is back, braces included
+local function load_revealed(screen)
+	local if_row = find_row(screen, "if err != nil {")
+	return if_row ~= nil
+		and screen[if_row + 1]:find("return nil, err", 1, true) ~= nil
+		and screen[if_row + 2]:find("}", 1, true) ~= nil
+end
+
+local function cursor_line()
+	return exec_lua("return vim.fn.line('.')")
+end
+
+local tests = {}
+
+tests["collapsed on load"] = function()
+	start("collapsed on load", {})
+	expect("the Load block to be collapsed", load_collapsed)
+	expect("the Save block to be collapsed", function(screen)
+		return not find_row(screen, "return err") and find_row(screen, "return nil") ~= nil
+	end)
+	stop()
+end
+
+tests["reveal on cursor"] = function()
+	start("reveal on cursor", { reveal_on_cursor = true })
+	expect("the Load block to be collapsed", load_collapsed)
+
+	request("nvim_input", "7G")
+	expect("the Load block to be revealed under the cursor", load_revealed)
+	expect("the Save block to stay collapsed", function(screen)
+		return not find_row(screen, "return err")
+	end)
+
+	-- inside the revealed block, it stays open
+	request("nvim_input", "j")
+	expect("the Load block to stay revealed inside it", load_revealed)
+
+	request("nvim_input", "gg")
+	expect("the Load block to collapse again", load_collapsed)
+	stop()
+end
+
+tests["smart j and k"] = function()
+	start("smart j and k", { reveal_on_cursor = false })
+	expect("the Load block to be collapsed", load_collapsed)
+
+	request("nvim_input", "6G")
+	expect("the cursor on the line before the block", function(screen)
+		return screen[child.cursor.row]:find("data, err :=", 1, true) ~= nil
+	end)
+
+	-- concealcursor keeps the brace hidden on the cursor line
+	request("nvim_input", "j")
+	expect("the cursor on the collapsed if line", function(screen)
+		return screen[child.cursor.row]:find("if err != nil", 1, true) ~= nil and load_collapsed(screen)
+	end)
+	if cursor_line() ~= 7 then
+		fail(child.test, "j from line 6 should land on line 7, not " .. cursor_line())
+	end
+
+	request("nvim_input", "j")
+	expect("j to skip over the hidden lines", function(screen)
+		return screen[child.cursor.row]:find("return data, nil", 1, true) ~= nil
+	end)
+	if cursor_line() ~= 10 then
+		fail(child.test, "j from line 7 should land on line 10, not " .. cursor_line())
+	end
+
+	request("nvim_input", "k")
+	expect("k to skip back over the hidden lines", function(screen)
+		return screen[child.cursor.row]:find("if err != nil", 1, true) ~= nil
+	end)
+	if cursor_line() ~= 7 then
+		fail(child.test, "k from line 10 should land on line 7, not " .. cursor_line())
+	end
+	stop()
+end
+
+tests["multiple windows"] = function()
+	start("multiple windows", { reveal_on_cursor = true })
+	expect("the Load block to be collapsed", load_collapsed)
+
+	local left_win = exec_lua("return vim.api.nvim_get_current_win()")
+	local right_win = exec_lua("vim.cmd('rightbelow vsplit') return vim.api.nvim_get_current_win()")
+	local left, right = window_position(left_win), window_position(right_win)
+	expect("two windows", function(screen)
+		return select(2, screen[1]:gsub("package fixtures", "")) == 2
+	end)
+
+	-- the cursor in one window reveals the block in both, the extmarks belong to the buffer
+	request("nvim_input", "7G")
+	expect("the Load block to be revealed in both windows", function()
+		return load_revealed(window_screen(left)) and load_revealed(window_screen(right))
+	end)
+
+	request("nvim_input", "gg")
+	expect("the Load block to collapse in both windows", function()
+		local left_rows, right_rows = window_screen(left), window_screen(right)
+		return not find_row(left_rows, "return nil, err") and not find_row(right_rows, "return nil, err")
+	end)
+
+	-- the cursor left inside the block in the other window keeps it revealed
+	request("nvim_input", "<C-w>h8G<C-w>l")
+	expect("the Load block to follow the cursor of the other window", function()
+		return load_revealed(window_screen(left)) and load_revealed(window_screen(right))
+	end)
+	stop()
+end
+
+local names = vim.tbl_keys(tests)
+table.sort(names)
+for _, name in ipairs(names) do
+	tests[name]()
+	print("ok " .. name)
+end
@@ -0,0 +1,261 @@
+-- Seeded randomized edits against the fixtures in tests/fixtures, checking the extmarks after each step.
+-- Every step applies a random insertion, deletion, undo, redo or cursor move, runs the plugin's update
+-- path (fold.process_buffer) and asserts that:
+--   - processing raised no error
+--   - every conceal lies inside a collapsed block, on rows and columns that exist
+--   - every inline marker sits on a column that exists
+--   - with reveal_on_cursor, no concealed line holds the cursor
+--
+-- Run from the repository root (the Go Treesitter parser must be on the runtimepath):
+--   nvim --headless -u NONE -l tests/stress.lua
+-- NO_GO_SEED replays a run, NO_GO_STEPS sets the number of steps per fixture (default 1000).
+
+vim.opt.runtimepath:prepend(vim.fn.getcwd())
+vim.cmd("filetype on")
+
+local config = require("no-go.config")
+local fold = require("no-go.fold")
+
+local seed = tonumber(os.getenv("NO_GO_SEED")) or os.time()
+local steps = tonumber(os.getenv("NO_GO_STEPS")) or 1000
+math.randomseed(seed)
+
+-- the last actions, printed on failure
+local history = {}
+
+local function fail(fixture, step, msg)
+	io.stderr:write(string.format("FAIL (seed %d, %s, step %d): %s\n", seed, fixture, step, msg))
+	for _, action in ipairs(history) do
+		io.stderr:write("  " .. action .. "\n")
+	end
+	os.exit(1)
+end
+
+local function record(action)
+	table.insert(history, action)
+	if #history > 20 then
+		table.remove(history, 1)
+	end
+end
+
+-- lines that make or break error blocks
+local snippets = {
+	"",
+	"\terr := f()",
+	"\tif err != nil {",
+	"\t\treturn err",
+	"\t}",
+	"}",
+	"\tif err != nil { return err }",
+	"\tif e != nil {",
+	'\t\tpanic("x")',
+	'\ts := "{"',
+	"\t// }",
+	"\t} else {",
+	"func g() error {",
+	"import (",
+	")",
+}
+
+-- characters for in-line edits
+local characters = { "{", "}", "(", ")", " ", "\t", "e", "r", "!", "=", "n", "i", "l", '"', "\n" }
+
+--- Pick a random row of the buffer
+local function random_row(bufnr)
+	return math.random(0, vim.api.nvim_buf_line_count(bufnr) - 1)
+end
+
+--- Pick a random column of a line, the end of line included
+local function random_col(bufnr, row)
+	local line = vim.api.nvim_buf_get_lines(bufnr, row, row + 1, false)[1] or ""
+	return math.random(0, #line)
+end
+
+--- Start a new undo block, so undo and redo step through the edits one by one
+local function break_undo()
+	vim.o.undolevels = vim.o.undolevels
+end
+
+local actions = {
+	insert_line = function(bufnr)
+		local row = math.random(0, vim.api.nvim_buf_line_count(bufnr))
+		local text = snippets[math.random(#snippets)]
+		vim.api.nvim_buf_set_lines(bufnr, row, row, false, { text })
+		return string.format("insert line %d %q", row, text)
+	end,
+	insert_text = function(bufnr)
+		local row = random_row(bufnr)
+		local col = random_col(bufnr, row)
+		local text = characters[math.random(#characters)]
+		vim.api.nvim_buf_set_text(bufnr, row, col, row, col, vim.split(text, "\n"))
+		return string.format("insert text %d:%d %q", row, col, text)
+	end,
+	delete_lines = function(bufnr)
+		local row = random_row(bufnr)
+		local count = math.random(1, 3)
+		vim.api.nvim_buf_set_lines(bufnr, row, math.min(row + count, vim.api.nvim_buf_line_count(bufnr)), false, {})
+		return string.format("delete lines %d +%d", row, count)
+	end,
+	delete_text = function(bufnr)
+		local start_row = random_row(bufnr)
+		local end_row = math.min(start_row + math.random(0, 1), vim.api.nvim_buf_line_count(bufnr) - 1)
+		local start_col = random_col(bufnr, start_row)
+		local end_col = random_col(bufnr, end_row)
+		if end_row == start_row and end_col < start_col then
+			start_col, end_col = end_col, start_col
+		end
+		vim.api.nvim_buf_set_text(bufnr, start_row, start_col, end_row, end_col, {})
+		return string.format("delete text %d:%d-%d:%d", start_row, start_col, end_row, end_col)
+	end,
+	undo = function()
+		vim.cmd("silent! undo")
+		return "undo"
+	end,
+	redo = function()
+		vim.cmd("silent! redo")
+		return "redo"
+	end,
+	move_cursor = function(bufnr, win)
+		local row = random_row(bufnr)
+		vim.api.nvim_win_set_cursor(win, { row + 1, random_col(bufnr, row) })
+		return string.format("move cursor %d", row)
+	end,
+}
+
+-- how often each action is picked
+local weights = {
+	{ "insert_line", 4 },
+	{ "insert_text", 4 },
+	{ "delete_lines", 2 },
+	{ "delete_text", 3 },
+	{ "undo", 2 },
+	{ "redo", 1 },
+	{ "move_cursor", 4 },
+}
+
+local function pick_action()
+	local total = 0
+	for _, entry in ipairs(weights) do
+		total = total + entry[2]
+	end
+	local pick = math.random(total)
+	for _, entry in ipairs(weights) do
+		pick = pick - entry[2]
+		if pick <= 0 then
+			return entry[1]
+		end
+	end
+end
+
+--- Check the invariants, return an error message when one is broken
+local function check(bufnr, win, opts)
+	local line_count = vim.api.nvim_buf_line_count(bufnr)
+	local blocks = fold.blocks[bufnr] or {}
+	local cursor_row = vim.api.nvim_win_get_cursor(win)[1] - 1
+
+	for _, block in ipairs(blocks) do
+		if block.start_row < 0 or block.end_row < block.start_row or block.end_row >= line_count then
+			local range = block.start_row .. "-" .. block.end_row
+			return string.format("block %s outside of the buffer (%d lines)", range, line_count)
+		end
+	end
+
+	local function line_length(row)
+		return #(vim.api.nvim_buf_get_lines(bufnr, row, row + 1, false)[1] or "")
+	end
+
+	for _, mark in ipairs(vim.api.nvim_buf_get_extmarks(bufnr, fold.namespace, 0, -1, { details = true })) do
+		local row, col, details = mark[2], mark[3], mark[4]
+		local end_row = details.end_row or row
+		local end_col = details.end_col or col
+
+		if details.conceal or details.conceal_lines then
+			if end_row >= line_count then
+				return string.format("conceal %d-%d past the last line (%d lines)", row, end_row, line_count)
+			end
+
+			local inside = false
+			for _, block in ipairs(blocks) do
+				if block.collapsed and row >= block.start_row and end_row <= block.end_row then
+					inside = true
+				end
+			end
+			if not inside then
+				return string.format("conceal %d:%d-%d:%d outside of any collapsed block", row, col, end_row, end_col)
+			end
+		end
+
+		if details.conceal then
+			if col > line_length(row) or end_col > line_length(end_row) then
+				return string.format("conceal %d:%d-%d:%d past the end of the line", row, col, end_row, end_col)
+			end
+			if opts.reveal_on_cursor and cursor_row == row then
+				return string.format("concealed line %d holds the cursor", row)
+			end
+		end
+
+		-- conceal_lines hides the rows from row to end_row, both included
+		if details.conceal_lines and opts.reveal_on_cursor then
+			if cursor_row >= row and cursor_row <= end_row then
+				return string.format("concealed lines %d-%d hold the cursor (row %d)", row, end_row, cursor_row)
+			end
+		end
+
+		if details.virt_text_pos == "inline" and col > line_length(row) then
+			return string.format("marker at %d:%d past the end of the line", row, col)
+		end
+	end
+
+	return nil
+end
+
+config.setup({
+	reveal_on_cursor = true,
+	fold_imports = true,
+	fold_assertions = true,
+	terminators = { "return", "panic", "log.Fatal*", "os.Exit" },
+})
+
+local fixtures = vim.fn.glob("tests/fixtures/*.go", false, true)
+if #fixtures == 0 then
+	io.stderr:write("FAIL: no fixtures in tests/fixtures\n")
+	os.exit(1)
+end
+
+for _, fixture in ipairs(fixtures) do
+	vim.cmd("edit! " .. vim.fn.fnameescape(fixture))
+	local bufnr = vim.api.nvim_get_current_buf()
+	local win = vim.api.nvim_get_current_win()
+	local opts = config.get(bufnr)
+	history = {}
+
+	local ok, err = pcall(fold.process_buffer, bufnr, opts)
+	if not ok then
+		fail(fixture, 0, "processing the fixture raised: " .. tostring(err))
+	end
+	if #(fold.blocks[bufnr] or {}) == 0 then
+		fail(fixture, 0, "the fixture has no blocks")
+	end
+
+	for step = 1, steps do
+		break_undo()
+		local name = pick_action()
+		local action_ok, action = pcall(actions[name], bufnr, win)
+		record(action_ok and action or (name .. " raised: " .. tostring(action)))
+
+		ok, err = pcall(fold.process_buffer, bufnr, opts)
+		if not ok then
+			fail(fixture, step, "process_buffer raised: " .. tostring(err))
+		end
+
+		local broken = check(bufnr, win, opts)
+		if broken then
+			fail(fixture, step, broken)
+		end
+	end
+
+	-- drop the edits, the fixture stays as it is on disk
+	vim.cmd("silent! bwipeout!")
+end
+
+print(string.format("ok (seed %d, %d steps x %d fixtures)", seed, steps, #fixtures))